@@ -0,0 +1,143 @@
+package assetio
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// Loader decodes image assets relative to a root directory.
+//
+// Images are decoded lazily on first request and cached. A failed load never
+// aborts: the caller gets a placeholder image together with the error, and the
+// failure is remembered for the missing asset report. Safe for concurrent use.
+type Loader struct {
+	root string
+
+	mu      sync.Mutex
+	images  map[string]image.Image
+	missing map[string]error
+}
+
+// MissingAsset is one asset that failed to load.
+type MissingAsset struct {
+	Path string
+	Err  error
+}
+
+func NewLoader(root string) *Loader {
+	if root == "" {
+		root = "."
+	}
+	return &Loader{
+		root:    root,
+		images:  make(map[string]image.Image),
+		missing: make(map[string]error),
+	}
+}
+
+// Root returns the directory asset paths are resolved against.
+func (l *Loader) Root() string { return l.root }
+
+// Path resolves an asset path like "import/tiles/x.png" against the root.
+// Absolute paths are returned unchanged.
+func (l *Loader) Path(rel string) string {
+	if filepath.IsAbs(rel) {
+		return rel
+	}
+	return filepath.Join(l.root, rel)
+}
+
+// Image returns the decoded image at rel. On failure it returns Placeholder()
+// and the error; later calls for the same path return the same result without
+// touching the disk again.
+func (l *Loader) Image(rel string) (image.Image, error) {
+	key := filepath.ToSlash(rel)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if img, ok := l.images[key]; ok {
+		return img, nil
+	}
+	if err, ok := l.missing[key]; ok {
+		return Placeholder(), err
+	}
+
+	img, err := decodeFile(l.Path(rel))
+	if err != nil {
+		l.missing[key] = err
+		return Placeholder(), err
+	}
+	l.images[key] = img
+	return img, nil
+}
+
+func decodeFile(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("decode %s: %w", path, err)
+	}
+	return img, nil
+}
+
+// Missing lists every asset that failed to load so far, sorted by path.
+func (l *Loader) Missing() []MissingAsset {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	list := make([]MissingAsset, 0, len(l.missing))
+	for p, err := range l.missing {
+		list = append(list, MissingAsset{Path: p, Err: err})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Path < list[j].Path })
+	return list
+}
+
+// Report writes a summary of the missing assets to w. It writes nothing when
+// everything loaded fine.
+func (l *Loader) Report(w io.Writer) {
+	missing := l.Missing()
+	if len(missing) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "%d asset(s) missing (root %s):\n", len(missing), l.root)
+	for _, m := range missing {
+		fmt.Fprintf(w, "  %s: %v\n", m.Path, m.Err)
+	}
+}
+
+var (
+	placeholderOnce sync.Once
+	placeholder     image.Image
+)
+
+// Placeholder is the "missing texture" image: a 16x16 magenta/black checkerboard.
+func Placeholder() image.Image {
+	placeholderOnce.Do(func() {
+		const size, cell = 16, 4
+		img := image.NewRGBA(image.Rect(0, 0, size, size))
+		magenta := color.RGBA{255, 0, 255, 255}
+		black := color.RGBA{0, 0, 0, 255}
+		for y := 0; y < size; y++ {
+			for x := 0; x < size; x++ {
+				if (x/cell+y/cell)%2 == 0 {
+					img.Set(x, y, magenta)
+				} else {
+					img.Set(x, y, black)
+				}
+			}
+		}
+		placeholder = img
+	})
+	return placeholder
+}
@@ -0,0 +1,3 @@
+module rpg/assetio
+
+go 1.22.0
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+
+	"rpg/assetio"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// assetManager turns images decoded by the shared assetio.Loader into ebiten
+// images. Everything is loaded lazily on first use; missing files come back as
+// the "missing texture" placeholder and are listed by report.
+type assetManager struct {
+	loader *assetio.Loader
+
+	mu          sync.Mutex
+	textures    map[string]*ebiten.Image
+	placeholder *ebiten.Image
+}
+
+var assets = newAssetManager(".")
+
+func newAssetManager(root string) *assetManager {
+	return &assetManager{
+		loader:   assetio.NewLoader(root),
+		textures: make(map[string]*ebiten.Image),
+	}
+}
+
+// image returns the texture at path (relative to the assets directory). On
+// error the placeholder is returned together with the error.
+func (a *assetManager) image(path string) (*ebiten.Image, error) {
+	key := filepath.ToSlash(path)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if img, ok := a.textures[key]; ok {
+		return img, nil
+	}
+	decoded, err := a.loader.Image(path)
+	if err != nil {
+		if a.placeholder == nil {
+			a.placeholder = ebiten.NewImageFromImage(decoded)
+		}
+		return a.placeholder, err
+	}
+	img := ebiten.NewImageFromImage(decoded)
+	a.textures[key] = img
+	return img, nil
+}
+
+// report prints the assets that failed to load so far.
+func (a *assetManager) report() {
+	a.loader.Report(os.Stdout)
+}
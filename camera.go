@@ -0,0 +1,149 @@
+package main
+
+import "math"
+
+// Camera tuning
+const (
+	CAMERA_MIN_ZOOM  = 0.5
+	CAMERA_MAX_ZOOM  = 2.5
+	CAMERA_ZOOM_STEP = 0.08 // zoom change per mouse wheel notch
+	// how fast zoom approaches its target (per second, exponential)
+	CAMERA_ZOOM_SMOOTHING = 12.0
+
+	// half size of the box around the camera centre the player can move in
+	// without the camera following (world units)
+	CAMERA_DEADZONE_X = 40
+	CAMERA_DEADZONE_Y = 30
+)
+
+// read more in gamestate
+//
+// The camera is simulation state: it's updated in the fixed tick by
+// updateCamera and only interpolated while drawing.
+type camera struct {
+	pos pos
+	// position at the start of the current simulation step
+	prevPos pos
+	// interpolated position used while drawing (set every Draw)
+	renderPos pos
+
+	//used in rendering
+	zoom float32
+	// zoom eases towards this
+	zoomTarget float32
+
+	// screen shake, see shake
+	shakeStrength float32
+	shakeDuration float64
+	shakeLeft     float64
+	shakeOffset   pos
+}
+
+func offsetsx(tobeoffset float32) float32 {
+	return ((tobeoffset-game.camera.renderPos.float_x)*game.camera.zoom + screenWidth/2)
+}
+func offsetsy(tobeoffset float32) float32 {
+	return ((tobeoffset-game.camera.renderPos.float_y)*game.camera.zoom + screenHeight/2)
+
+}
+
+// setZoom jumps straight to a zoom level.
+func (c *camera) setZoom(z float32) {
+	c.zoom = clampFloat(z, CAMERA_MIN_ZOOM, CAMERA_MAX_ZOOM)
+	c.zoomTarget = c.zoom
+}
+
+// zoomBy moves the zoom target by mouse wheel notches.
+func (c *camera) zoomBy(notches float64) {
+	c.zoomTarget = clampFloat(c.zoomTarget+float32(notches)*CAMERA_ZOOM_STEP, CAMERA_MIN_ZOOM, CAMERA_MAX_ZOOM)
+}
+
+// snapTo centres the camera on p without easing or interpolation.
+func (c *camera) snapTo(p pos) {
+	c.pos = p
+	c.clampToMap()
+	c.prevPos = c.pos
+	c.renderPos = c.pos
+}
+
+// shake starts a screen shake; a stronger shake overrides a weaker running one.
+func (c *camera) shake(strength float32, duration float64) {
+	if c.shakeLeft > 0 && c.shakeStrength*float32(c.shakeLeft/c.shakeDuration) > strength {
+		return
+	}
+	c.shakeStrength = strength
+	c.shakeDuration = duration
+	c.shakeLeft = duration
+}
+
+// update runs once per simulation step.
+func (c *camera) update(dt float64, target *character) {
+	// ease zoom
+	k := float32(1 - math.Exp(-CAMERA_ZOOM_SMOOTHING*dt))
+	c.zoom += (c.zoomTarget - c.zoom) * k
+	if math.Abs(float64(c.zoomTarget-c.zoom)) < 0.001 {
+		c.zoom = c.zoomTarget
+	}
+
+	// follow with a deadzone
+	if target != nil {
+		dx := target.pos.float_x - c.pos.float_x
+		dy := target.pos.float_y - c.pos.float_y
+		if dx > CAMERA_DEADZONE_X {
+			c.pos.float_x += dx - CAMERA_DEADZONE_X
+		} else if dx < -CAMERA_DEADZONE_X {
+			c.pos.float_x += dx + CAMERA_DEADZONE_X
+		}
+		if dy > CAMERA_DEADZONE_Y {
+			c.pos.float_y += dy - CAMERA_DEADZONE_Y
+		} else if dy < -CAMERA_DEADZONE_Y {
+			c.pos.float_y += dy + CAMERA_DEADZONE_Y
+		}
+	}
+	c.clampToMap()
+
+	// shake decays linearly
+	c.shakeOffset = pos{}
+	if c.shakeLeft > 0 {
+		c.shakeLeft -= dt
+		if c.shakeLeft > 0 {
+			rnd := game.rng.stream(rngFX)
+			s := c.shakeStrength * float32(c.shakeLeft/c.shakeDuration)
+			c.shakeOffset = createPos((rnd.Float32()*2-1)*s, (rnd.Float32()*2-1)*s)
+		}
+	}
+}
+
+// clampToMap keeps the view inside the map, or centred on it when the map
+// is smaller than the view.
+func (c *camera) clampToMap() {
+	if game.currentmap.width == 0 || game.currentmap.height == 0 || c.zoom <= 0 {
+		return
+	}
+	// tiles are drawn centred on i*screendivisor
+	minX := -screendivisor / 2
+	minY := -screendivisor / 2
+	maxX := float32(game.currentmap.width)*screendivisor + minX
+	maxY := float32(game.currentmap.height)*screendivisor + minY
+	halfW := screenWidth / 2 / c.zoom
+	halfH := screenHeight / 2 / c.zoom
+
+	c.pos.float_x = clampAxis(c.pos.float_x, minX+halfW, maxX-halfW)
+	c.pos.float_y = clampAxis(c.pos.float_y, minY+halfH, maxY-halfH)
+}
+
+func clampAxis(v, lo, hi float32) float32 {
+	if lo > hi {
+		return (lo + hi) / 2
+	}
+	return clampFloat(v, lo, hi)
+}
+
+// updateCamera follows the first player.
+func updateCamera(dt float64) {
+	var target *character
+	if len(game.entities.players) > 0 {
+		target = game.entities.players[0]
+	}
+	game.camera.update(dt, target)
+}
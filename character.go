@@ -2,7 +2,6 @@ package main
 
 import (
 	"math"
-	"math/rand/v2"
 
 	"github.com/hajimehoshi/ebiten/v2"
 )
@@ -10,7 +9,6 @@ import (
 const (
 	CHARSPEED   = 260 // was 200
 	DASHSPEED   = 800 // was 700
-	BOOSTSPEED  = 340 // was 300
 	ATTACKSPEED = 160 // character movement speed while attacking
 
 	// Damage randomness
@@ -21,9 +19,11 @@ const (
 )
 
 type character struct {
-	pos     pos
+	pos pos
+	// position at the start of the current simulation step (render interpolation)
+	prevPos pos
 	texture *ebiten.Image
-	id      int
+	id      entityID
 
 	speed              float32
 	offsetForAnimation int
@@ -54,6 +54,12 @@ type character struct {
 	animPlayer AnimationPlayer
 	// cached state to decide which animation to play
 	currentAnimName string
+
+	// last tile the player stood on, for TileEntered
+	tileX, tileY int
+	onTile       bool
+	// standing in a portal that already fired (or was arrived in)
+	inPortal bool
 }
 
 func createCharacter() {
@@ -62,40 +68,45 @@ func createCharacter() {
 	c.hp = 100
 	c.uiHp = c.hp
 	c.pos = createPos(screenWidth/2, screenHeight/2)
+	c.prevPos = c.pos
 	c.speed = CHARSPEED
 
-	c.offsetForAnimation = rand.IntN(5)
+	c.offsetForAnimation = game.rng.stream(rngFX).Intn(5)
 
-	drawables = append(drawables, &c)
-	game.currentmap.players = append(game.currentmap.players, &c)
+	game.entities.add(&c)
 }
 
+// nearestCharacter returns the closest player, nil if there is none left.
 func nearestCharacter(pos pos) *character {
-	var closest int
-	var closestDistance float32
+	var closest *character
+	closestDistance := float32(math.MaxFloat32)
 
-	for i := 0; i < len(game.currentmap.players); i++ {
-		if closestDistance > Distance(pos, game.currentmap.players[i].pos) {
-			closestDistance = Distance(pos, game.currentmap.players[i].pos)
-			closest = i
+	for _, c := range game.entities.players {
+		if d := Distance(pos, c.pos); d < closestDistance {
+			closestDistance = d
+			closest = c
 		}
 	}
 
-	return game.currentmap.players[closest]
+	return closest
 }
 
 func charactersInRange(pos pos, distance float32) []*character {
 	var cs []*character
-	for i := 0; i < len(game.currentmap.players); i++ {
-		if Distance(pos, game.currentmap.players[i].pos) > distance {
-			cs = append(cs, game.currentmap.players[i])
+	for i := 0; i < len(game.entities.players); i++ {
+		if Distance(pos, game.entities.players[i].pos) > distance {
+			cs = append(cs, game.entities.players[i])
 		}
 	}
 	return cs
 }
 
-func (c *character) updateCamera() {
-	game.camera.pos = c.pos
+// teleport moves the player without interpolating from the old position and
+// snaps the camera along.
+func (c *character) teleport(p pos) {
+	c.pos = p
+	c.prevPos = p
+	game.camera.snapTo(p)
 }
 
 func (c *character) updateAnimation() {
@@ -187,6 +198,7 @@ func (c *character) attack() {
 	}
 
 	es := enemiesInRange(c.pos, 80)
+	rolls := game.rng.stream(rngCombat)
 
 	for i := 0; i < len(es); i++ {
 		e := es[i]
@@ -194,31 +206,40 @@ func (c *character) attack() {
 			continue
 		}
 		// Roll base damage in range
-		varDmg := MIN_DAMAGE + rand.Float32()*(MAX_DAMAGE-MIN_DAMAGE)
+		varDmg := MIN_DAMAGE + rolls.Float32()*(MAX_DAMAGE-MIN_DAMAGE)
 		crit := false
 		// Critical hit roll
-		if rand.Float32() < CRIT_CHANCE {
+		if rolls.Float32() < CRIT_CHANCE {
 			varDmg *= CRIT_MULTIPLIER
 			crit = true
 		}
 		e.hp -= float32(varDmg)
 		e.hit = true
 		e.sinceHit = 0.2
-		applyKnockback(e, c, KNOCKBACK_BASE_STRENGTH)
-		AddDamageIndicator(e.pos, float32(varDmg), crit)
+		game.events.enemyDamaged.publish(EnemyDamaged{Enemy: e, Source: c, Amount: float32(varDmg), Crit: crit})
 	}
 
 }
 
 func (c *character) checkHp() {
 	if c.hp < 1 {
-		removeAtID(c.id, drawables)
+		game.entities.remove(c.id)
+	}
+}
+
+// checkTileEntered publishes TileEntered when the player crosses onto another tile.
+func (c *character) checkTileEntered() {
+	x, y := ptid(c.pos)
+	if c.onTile && x == c.tileX && y == c.tileY {
+		return
 	}
+	c.tileX, c.tileY, c.onTile = x, y, true
+	game.events.tileEntered.publish(TileEntered{Player: c, X: x, Y: y, Tile: safeTile(y, x)})
 }
 
 func (c *character) todoCharacter() {
 	c.checkHp()
-	c.updateCamera()
 	c.checkMovement()
+	c.checkTileEntered()
 	c.updateAnimation()
 }
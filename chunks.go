@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"math"
+
+	"rpg/mapio"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// Map streaming. The tiles of the current map are kept in chunks
+// (mapio.Chunk) that are loaded around the camera and dropped once it's far
+// away, so drawing cost depends on the view, not the map size. Memory only
+// does for chunked maps (a .chunks directory): a single file map is loaded
+// whole and its chunks are cut from the MapData in memory (MemorySource),
+// so the full tile arrays stay resident. Convert big maps with
+// `maptool convert map.json world.chunks`.
+const (
+	// chunks around the view loaded ahead of the camera
+	CHUNK_LOAD_MARGIN = 1
+	// loaded chunks further than this from the view are dropped...
+	CHUNK_DROP_MARGIN = 3
+	// ...once nothing has read them for this many ticks
+	CHUNK_IDLE_TICKS = 120
+)
+
+type chunkKey struct{ x, y int }
+
+// worldChunk is a loaded chunk of the current map. Textures are looked up
+// the first time it's drawn, so a headless run never touches them.
+type worldChunk struct {
+	*mapio.Chunk
+	textured                bool
+	ground, detail, overlay [][]*ebiten.Image
+	// sim tick the chunk was last read on
+	lastUsed int
+}
+
+// chunkCache holds the loaded chunks of the current map.
+type chunkCache struct {
+	source mapio.ChunkSource
+	size   int
+	loaded map[chunkKey]*worldChunk
+}
+
+func createChunkCache(source mapio.ChunkSource) chunkCache {
+	return chunkCache{source: source, size: source.ChunkSize(), loaded: make(map[chunkKey]*worldChunk)}
+}
+
+// chunk returns a chunk, loading it if needed. nil outside the map or when
+// it can't be read (its tiles are void then).
+func (cc *chunkCache) chunk(cx, cy int) *worldChunk {
+	if cc.source == nil {
+		return nil
+	}
+	k := chunkKey{cx, cy}
+	if c, ok := cc.loaded[k]; ok {
+		c.lastUsed = game.sim.tick
+		return c
+	}
+	if cx < 0 || cy < 0 || cx*cc.size >= game.currentmap.width || cy*cc.size >= game.currentmap.height {
+		return nil
+	}
+	c, err := cc.source.LoadChunk(cx, cy)
+	if err != nil {
+		fmt.Println("Failed to load map chunk:", err)
+		c = mapio.NewChunk(cx, cy, cc.size)
+	}
+	wc := &worldChunk{Chunk: c, lastUsed: game.sim.tick}
+	cc.loaded[k] = wc
+	return wc
+}
+
+// layerTile returns a tile of a layer of the current map, 0 outside of it.
+func layerTile(layer string, y, x int) int {
+	if y < 0 || y >= game.currentmap.height || x < 0 || x >= game.currentmap.width {
+		return 0
+	}
+	cc := &game.currentmap.chunks
+	if cc.source == nil {
+		return 0
+	}
+	cx, cy := mapio.ChunkOf(x, y, cc.size)
+	c := cc.chunk(cx, cy)
+	if c == nil {
+		return 0
+	}
+	ox, oy := c.Origin()
+	return c.Get(layer, x-ox, y-oy)
+}
+
+// viewTiles returns the (inclusive) tile range seen by a camera at center,
+// with a tile to spare for the screen shake.
+func viewTiles(center pos) (x0, y0, x1, y1 int) {
+	halfW := screenWidth / 2 / game.camera.zoom
+	halfH := screenHeight / 2 / game.camera.zoom
+	// tiles are drawn centred on i*screendivisor
+	tile := func(v float32) int {
+		return int(math.Floor(float64((v + screendivisor/2) / screendivisor)))
+	}
+	return tile(center.float_x-halfW) - 1, tile(center.float_y-halfH) - 1,
+		tile(center.float_x+halfW) + 1, tile(center.float_y+halfH) + 1
+}
+
+// streamChunks loads the chunks coming into view and drops the ones the
+// camera left behind. Called every tick after the camera moved.
+func streamChunks() {
+	cc := &game.currentmap.chunks
+	if cc.source == nil {
+		return
+	}
+	x0, y0, x1, y1 := viewTiles(game.camera.pos)
+	cx0, cy0 := mapio.ChunkOf(x0, y0, cc.size)
+	cx1, cy1 := mapio.ChunkOf(x1, y1, cc.size)
+
+	for cy := cy0 - CHUNK_LOAD_MARGIN; cy <= cy1+CHUNK_LOAD_MARGIN; cy++ {
+		for cx := cx0 - CHUNK_LOAD_MARGIN; cx <= cx1+CHUNK_LOAD_MARGIN; cx++ {
+			cc.chunk(cx, cy)
+		}
+	}
+
+	for k, c := range cc.loaded {
+		far := k.x < cx0-CHUNK_DROP_MARGIN || k.x > cx1+CHUNK_DROP_MARGIN ||
+			k.y < cy0-CHUNK_DROP_MARGIN || k.y > cy1+CHUNK_DROP_MARGIN
+		if far && game.sim.tick-c.lastUsed > CHUNK_IDLE_TICKS {
+			delete(cc.loaded, k)
+		}
+	}
+}
+
+// buildTextures looks up the textures of every tile of the chunk. Variants
+// are rolled from a generator of the chunk's own, so a chunk looks the same
+// every time it's loaded.
+func (c *worldChunk) buildTextures() {
+	rnd := game.rng.at(rngWorld, c.X, c.Y)
+	ox, oy := c.Origin()
+	build := func(layer string) [][]*ebiten.Image {
+		if c.LayerTiles(layer) == nil {
+			return nil
+		}
+		get := func(y, x int) int { return layerTile(layer, y, x) }
+		tex := make([][]*ebiten.Image, c.Size)
+		for y := range tex {
+			tex[y] = make([]*ebiten.Image, c.Size)
+			for x := range tex[y] {
+				if ox+x >= game.currentmap.width || oy+y >= game.currentmap.height {
+					continue // chunk padding past the map edge
+				}
+				// 0 is "nothing" on every layer but the ground (void)
+				if layer == mapio.LayerGround || c.Get(layer, x, y) != 0 {
+					tex[y][x] = tileTexture(get, oy+y, ox+x, rnd)
+				}
+			}
+		}
+		return tex
+	}
+	c.ground = build(mapio.LayerGround)
+	c.detail = build(mapio.LayerDetail)
+	c.overlay = build(mapio.LayerOverlay)
+	c.textured = true
+}
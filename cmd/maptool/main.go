@@ -0,0 +1,337 @@
+// Command maptool checks, converts and previews maps without a display.
+//
+//	go run ./cmd/maptool lint map.txt
+//	go run ./cmd/maptool convert map.txt map.json
+//	go run ./cmd/maptool stats map.txt
+//	go run ./cmd/maptool resize -width 200 -height 120 map.txt
+//	go run ./cmd/maptool navgen -spacing 4 map.txt
+//	go run ./cmd/maptool render -o map.png map.txt
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"rpg/mapio"
+)
+
+type command struct {
+	name  string
+	args  string
+	usage string
+	run   func(args []string) error
+}
+
+var commands = []command{
+	{"lint", "[-strict] [-quiet] map...", "check maps, exit code 1 on errors (or warnings with -strict)", runLint},
+	{"convert", "src dst", "convert between formats, picked from the extension (.json, .chunks directory or legacy text, Tiled .tmx/.tmj as source)", runConvert},
+	{"stats", "map...", "print tile histograms, nav graph, NPC and spawner counts", runStats},
+	{"resize", "-width W -height H [-fill id] [-o dst] map", "resize a map keeping its top left corner", runResize},
+	{"navgen", "[-spacing N] [-o dst] map", "replace the nav graph with one generated from the walkable tiles", runNavgen},
+	{"render", "[-o out.png] [-scale px] [-textures] [-objects=false] map", "render a PNG preview", runRender},
+}
+
+// -assets is shared by every subcommand, like the game's launcher flag
+var assetsDir = "."
+
+func main() {
+	global := flag.NewFlagSet("maptool", flag.ExitOnError)
+	global.StringVar(&assetsDir, "assets", assetsDir, "directory containing import/ (for tiles.json and textures)")
+	global.Usage = usage
+	global.Parse(os.Args[1:])
+
+	args := global.Args()
+	if len(args) == 0 {
+		usage()
+		os.Exit(2)
+	}
+	for _, c := range commands {
+		if c.name == args[0] {
+			if err := c.run(args[1:]); err != nil {
+				fmt.Fprintln(os.Stderr, "maptool "+c.name+":", err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+	fmt.Fprintf(os.Stderr, "maptool: unknown command %q\n", args[0])
+	usage()
+	os.Exit(2)
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: maptool [-assets dir] <command> [arguments]")
+	fmt.Fprintln(os.Stderr)
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-8s %s\n  %-8s   %s\n", c.name, c.args, "", c.usage)
+	}
+}
+
+// newFlags returns the flag set of a subcommand.
+func newFlags(name, args string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: maptool %s %s\n", name, args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// loadTiles reads the tile definitions, nil (with a warning) if there are none.
+func loadTiles() *mapio.TileSet {
+	ts, err := mapio.LoadTileSet(filepath.Join(assetsDir, "import", "tiles.json"))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "warning:", err)
+		return nil
+	}
+	return ts
+}
+
+// loadMap decodes a map without the loader's progress output.
+func loadMap(path string) (*mapio.MapData, error) {
+	if mapio.IsChunkedMap(path) {
+		s, err := mapio.OpenChunkStore(path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		return s.LoadAll()
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	m, err := mapio.Decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return m, nil
+}
+
+func runLint(args []string) error {
+	fs := newFlags("lint", "[-strict] [-quiet] map...")
+	strict := fs.Bool("strict", false, "fail on warnings too")
+	quiet := fs.Bool("quiet", false, "only print errors")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	tiles := loadTiles()
+	failed := false
+	for _, path := range fs.Args() {
+		m, err := loadMap(path)
+		if err != nil {
+			fmt.Println(err)
+			failed = true
+			continue
+		}
+		diags := mapio.ValidateWithTiles(m, tiles)
+		for _, d := range diags {
+			if *quiet && d.Severity != mapio.SeverityError {
+				continue
+			}
+			fmt.Printf("%s: %s\n", path, d)
+		}
+		errs, warns := diags.Count()
+		if errs > 0 || (*strict && warns > 0) {
+			failed = true
+		}
+	}
+	if failed {
+		return fmt.Errorf("problems found")
+	}
+	return nil
+}
+
+func runConvert(args []string) error {
+	fs := newFlags("convert", "src dst")
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	src, dst := fs.Arg(0), fs.Arg(1)
+	m, err := loadMap(src)
+	if err != nil {
+		return err
+	}
+	if err := mapio.SaveMapToFile(m, dst); err != nil {
+		return err
+	}
+	fmt.Printf("%s -> %s (%s)\n", src, dst, mapio.FormatForPath(dst))
+	return nil
+}
+
+func runResize(args []string) error {
+	fs := newFlags("resize", "-width W -height H [-fill id] [-o dst] map")
+	width := fs.Int("width", 0, "new width in tiles")
+	height := fs.Int("height", 0, "new height in tiles")
+	fill := fs.Int("fill", 0, "ground tile id for the added area")
+	out := fs.String("o", "", "output file (default: overwrite the map)")
+	fs.Parse(args)
+	if fs.NArg() != 1 || *width <= 0 || *height <= 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	src := fs.Arg(0)
+	dst := *out
+	if dst == "" {
+		dst = src
+	}
+
+	m, err := loadMap(src)
+	if err != nil {
+		return err
+	}
+	oldW, oldH := m.Width, m.Height
+	m.Resize(*width, *height, *fill)
+	if err := mapio.SaveMapToFile(m, dst); err != nil {
+		return err
+	}
+	fmt.Printf("%s: %dx%d -> %dx%d, written to %s\n", src, oldW, oldH, m.Width, m.Height, dst)
+	for _, d := range mapio.Validate(m) {
+		if d.Code == mapio.DiagOutOfBounds {
+			fmt.Println(d)
+		}
+	}
+	return nil
+}
+
+func runNavgen(args []string) error {
+	fs := newFlags("navgen", "[-spacing N] [-o dst] map")
+	spacing := fs.Int("spacing", mapio.DefaultNavSpacing, "tiles between nodes")
+	out := fs.String("o", "", "output file (default: overwrite the map)")
+	fs.Parse(args)
+	if fs.NArg() != 1 || *spacing <= 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	src := fs.Arg(0)
+	dst := *out
+	if dst == "" {
+		dst = src
+	}
+
+	m, err := loadMap(src)
+	if err != nil {
+		return err
+	}
+	oldNodes, oldPaths := len(m.Nodes), len(m.Paths)
+	nodes, paths := m.GenerateNavGraph(mapio.NavGenOptions{Spacing: *spacing, Tiles: loadTiles()})
+	if err := mapio.SaveMapToFile(m, dst); err != nil {
+		return err
+	}
+	fmt.Printf("%s: %d nodes, %d paths -> %d nodes, %d paths, written to %s\n", src, oldNodes, oldPaths, nodes, paths, dst)
+	return nil
+}
+
+func runStats(args []string) error {
+	fs := newFlags("stats", "map...")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	tiles := loadTiles()
+	for i, path := range fs.Args() {
+		if i > 0 {
+			fmt.Println()
+		}
+		m, err := loadMap(path)
+		if err != nil {
+			return err
+		}
+		printStats(path, m, tiles)
+	}
+	return nil
+}
+
+func printStats(path string, m *mapio.MapData, tiles *mapio.TileSet) {
+	fmt.Printf("%s: %dx%d tiles\n", path, m.Width, m.Height)
+
+	for _, layer := range mapio.LayerNames {
+		grid := m.LayerTiles(layer)
+		if grid == nil {
+			continue
+		}
+		counts := map[int]int{}
+		total := 0
+		for _, row := range grid {
+			for _, v := range row {
+				counts[v]++
+				total++
+			}
+		}
+		ids := make([]int, 0, len(counts))
+		for id := range counts {
+			ids = append(ids, id)
+		}
+		sort.Ints(ids)
+		fmt.Printf("  %s:\n", layer)
+		for _, id := range ids {
+			fmt.Printf("    %4d %-12s %7d  %5.1f%%\n", id, tileName(layer, id, tiles), counts[id], 100*float64(counts[id])/float64(total))
+		}
+	}
+
+	var cost float32
+	for _, p := range m.Paths {
+		cost += p.Cost
+	}
+	fmt.Printf("  nodes: %d, paths: %d (total cost %.0f)\n", len(m.Nodes), len(m.Paths), cost)
+
+	sprites := map[int]int{}
+	for _, s := range m.Sprites {
+		sprites[s.Type]++
+	}
+	var parts []string
+	for t, n := range sprites {
+		parts = append(parts, fmt.Sprintf("type %d: %d", t, n))
+	}
+	sort.Strings(parts)
+	fmt.Printf("  sprites: %d", len(m.Sprites))
+	if len(parts) > 0 {
+		fmt.Printf(" (%s)", strings.Join(parts, ", "))
+	}
+	fmt.Println()
+
+	lines := 0
+	for _, n := range m.NPCs {
+		lines += len(n.Dialogues)
+	}
+	fmt.Printf("  NPCs: %d (%d dialogue lines)\n", len(m.NPCs), lines)
+
+	capacity := 0
+	for _, sp := range m.Spawners {
+		capacity += sp.MaxAlive
+	}
+	fmt.Printf("  spawners: %d (up to %d enemies alive)\n", len(m.Spawners), capacity)
+	fmt.Printf("  portals: %d, spawn points: %d\n", len(m.Portals), len(m.SpawnPoints))
+
+	errs, warns := mapio.ValidateWithTiles(m, tiles).Count()
+	fmt.Printf("  lint: %d errors, %d warnings\n", errs, warns)
+}
+
+func tileName(layer string, id int, tiles *mapio.TileSet) string {
+	if layer == mapio.LayerCollision {
+		switch id {
+		case mapio.CollisionDefault:
+			return "auto"
+		case mapio.CollisionBlocked:
+			return "blocked"
+		case mapio.CollisionWalkable:
+			return "walkable"
+		}
+	}
+	if id == 0 && layer != mapio.LayerGround {
+		return "empty"
+	}
+	if def := tiles.Get(id); def != nil {
+		return def.Name
+	}
+	return "?"
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"os"
+	"strings"
+
+	"rpg/assetio"
+	"rpg/mapio"
+
+	"golang.org/x/image/draw"
+)
+
+var (
+	pathColor    = color.RGBA{160, 20, 20, 255}
+	nodeColor    = color.RGBA{200, 40, 40, 255}
+	npcColor     = color.RGBA{200, 180, 60, 255}
+	spawnerColor = color.RGBA{200, 80, 80, 255}
+	spriteColor  = color.RGBA{20, 90, 30, 255}
+)
+
+func runRender(args []string) error {
+	fs := newFlags("render", "[-o out.png] [-scale px] [-textures] [-objects=false] map")
+	out := fs.String("o", "", "output PNG (default: the map name with .png)")
+	scale := fs.Int("scale", 4, "pixels per tile")
+	textures := fs.Bool("textures", false, "draw tile textures instead of editor colors")
+	objects := fs.Bool("objects", true, "draw nodes, paths, sprites, NPCs and spawners")
+	fs.Parse(args)
+	if fs.NArg() != 1 || *scale <= 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	src := fs.Arg(0)
+	dst := *out
+	if dst == "" {
+		dst = strings.TrimSuffix(src, fileExt(src)) + ".png"
+	}
+
+	m, err := loadMap(src)
+	if err != nil {
+		return err
+	}
+	r := renderer{m: m, tiles: loadTiles(), scale: *scale}
+	if *textures {
+		r.loader = assetio.NewLoader(assetsDir)
+		r.cache = map[string]image.Image{}
+	}
+	img := r.render(*objects)
+
+	f, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := png.Encode(f, img); err != nil {
+		return err
+	}
+	fmt.Printf("%s -> %s (%dx%d px)\n", src, dst, img.Bounds().Dx(), img.Bounds().Dy())
+	if r.loader != nil {
+		r.loader.Report(os.Stdout)
+	}
+	return nil
+}
+
+func fileExt(path string) string {
+	if i := strings.LastIndexByte(path, '.'); i > strings.LastIndexAny(path, `/\`) {
+		return path[i:]
+	}
+	return ""
+}
+
+type renderer struct {
+	m      *mapio.MapData
+	tiles  *mapio.TileSet
+	scale  int
+	loader *assetio.Loader // nil: flat editor colors
+	cache  map[string]image.Image
+}
+
+func (r *renderer) render(objects bool) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, r.m.Width*r.scale, r.m.Height*r.scale))
+	for _, layer := range []string{mapio.LayerGround, mapio.LayerDetail, mapio.LayerOverlay} {
+		grid := r.m.LayerTiles(layer)
+		for y, row := range grid {
+			for x, id := range row {
+				if id == 0 && layer != mapio.LayerGround {
+					continue
+				}
+				r.drawTile(img, grid, layer, x, y, id)
+			}
+		}
+	}
+	if objects {
+		r.drawObjects(img)
+	}
+	return img
+}
+
+func (r *renderer) drawTile(img *image.RGBA, grid [][]int, layer string, x, y, id int) {
+	rect := image.Rect(x*r.scale, y*r.scale, (x+1)*r.scale, (y+1)*r.scale)
+	def := r.tiles.Get(id)
+	if def == nil {
+		if layer == mapio.LayerGround {
+			draw.Draw(img, rect, image.NewUniform(color.RGBA{64, 64, 64, 255}), image.Point{}, draw.Src)
+		}
+		return
+	}
+	if r.loader != nil {
+		if tex := r.texture(def, grid, x, y); tex != nil {
+			draw.NearestNeighbor.Scale(img, rect, tex, tex.Bounds(), draw.Over, nil)
+			return
+		}
+	}
+	// decorations are drawn smaller so the ground stays visible
+	if layer != mapio.LayerGround {
+		inset := r.scale / 4
+		rect = rect.Inset(inset)
+	}
+	draw.Draw(img, rect, image.NewUniform(def.Color()), image.Point{}, draw.Over)
+}
+
+// texture picks the same texture the game would, except that variants always
+// use the first one so previews don't flicker between runs.
+func (r *renderer) texture(def *mapio.TileDef, grid [][]int, x, y int) image.Image {
+	path := ""
+	if def.Autotile != nil {
+		same := func(x, y int) bool {
+			return y >= 0 && y < len(grid) && x >= 0 && x < len(grid[y]) && grid[y][x] == def.ID
+		}
+		path = def.Autotile.Texture(def.Autotile.Mask(same(x, y-1), same(x-1, y), same(x+1, y), same(x, y+1)))
+	} else if len(def.Variants) > 0 {
+		path = def.Variants[0].Texture
+	}
+	if path == "" {
+		return nil
+	}
+	if img, ok := r.cache[path]; ok {
+		return img
+	}
+	img, err := r.loader.Image(path)
+	if err != nil {
+		img = nil // reported at the end, fall back on the color
+	}
+	r.cache[path] = img
+	return img
+}
+
+func (r *renderer) drawObjects(img *image.RGBA) {
+	// world units -> pixels
+	px := func(p mapio.Pos) (int, int) {
+		return int(p.X * float32(r.scale) / mapio.TileSize), int(p.Y * float32(r.scale) / mapio.TileSize)
+	}
+	dot := max(r.scale/2, 2)
+
+	for _, s := range r.m.Sprites {
+		x, y := px(s.Pos)
+		fillCircle(img, x, y, dot/2+1, spriteColor)
+	}
+	for _, p := range r.m.Paths {
+		a, b := r.m.FindNodeByID(p.NodeAID), r.m.FindNodeByID(p.NodeBID)
+		if a == nil || b == nil {
+			continue
+		}
+		ax, ay := px(a.Pos)
+		bx, by := px(b.Pos)
+		drawLine(img, ax, ay, bx, by, pathColor)
+	}
+	for _, n := range r.m.Nodes {
+		x, y := px(n.Pos)
+		fillCircle(img, x, y, dot, nodeColor)
+	}
+	for _, sp := range r.m.Spawners {
+		x, y := px(sp.Pos)
+		fillCircle(img, x, y, dot+1, spawnerColor)
+		radius := int(sp.Radius * float32(r.scale) / mapio.TileSize)
+		strokeCircle(img, x, y, radius, spawnerColor)
+	}
+	for _, n := range r.m.NPCs {
+		x, y := px(n.Pos)
+		fillCircle(img, x, y, dot+1, npcColor)
+	}
+}
+
+func fillCircle(img *image.RGBA, cx, cy, radius int, c color.RGBA) {
+	for y := -radius; y <= radius; y++ {
+		for x := -radius; x <= radius; x++ {
+			if x*x+y*y <= radius*radius {
+				setPixel(img, cx+x, cy+y, c)
+			}
+		}
+	}
+}
+
+func strokeCircle(img *image.RGBA, cx, cy, radius int, c color.RGBA) {
+	if radius <= 0 {
+		return
+	}
+	steps := int(2 * math.Pi * float64(radius))
+	for i := 0; i < steps; i++ {
+		a := 2 * math.Pi * float64(i) / float64(steps)
+		setPixel(img, cx+int(math.Round(float64(radius)*math.Cos(a))), cy+int(math.Round(float64(radius)*math.Sin(a))), c)
+	}
+}
+
+// drawLine draws a 1px line (Bresenham).
+func drawLine(img *image.RGBA, x0, y0, x1, y1 int, c color.RGBA) {
+	dx, dy := abs(x1-x0), -abs(y1-y0)
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+	e := dx + dy
+	for {
+		setPixel(img, x0, y0, c)
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		e2 := 2 * e
+		if e2 >= dy {
+			e += dy
+			x0 += sx
+		}
+		if e2 <= dx {
+			e += dx
+			y0 += sy
+		}
+	}
+}
+
+func setPixel(img *image.RGBA, x, y int, c color.RGBA) {
+	if image.Pt(x, y).In(img.Bounds()) {
+		img.SetRGBA(x, y, c)
+	}
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
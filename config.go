@@ -0,0 +1,99 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// launchConfig holds the command line options.
+type launchConfig struct {
+	// map file to load (relative to the working directory)
+	mapPath string
+
+	seed    int64
+	seedSet bool
+
+	// windowed mode instead of fullscreen, with the given size
+	windowed     bool
+	windowWidth  int
+	windowHeight int
+
+	// root directory of import/ and music/
+	assetsDir string
+
+	mute         bool
+	startInGame  bool
+	debugOverlay bool
+
+	headlessTicks int
+	recordPath    string
+	replayPath    string
+	check         bool
+}
+
+var cfg = launchConfig{
+	mapPath:   "map.txt",
+	assetsDir: ".",
+}
+
+// parseLaunchConfig reads the command line into cfg.
+func parseLaunchConfig() error {
+	var windowed string
+	flag.StringVar(&cfg.mapPath, "map", cfg.mapPath, "map file to load")
+	flag.Int64Var(&cfg.seed, "seed", 0, "random seed for a reproducible run (default: time based)")
+	flag.StringVar(&windowed, "windowed", "", "run in a window of the given size instead of fullscreen, e.g. 1280x720")
+	flag.StringVar(&cfg.assetsDir, "assets", cfg.assetsDir, "directory containing import/ and music/")
+	flag.BoolVar(&cfg.mute, "mute", false, "don't play any audio")
+	flag.BoolVar(&cfg.startInGame, "start-in-game", false, "skip the main menu")
+	flag.BoolVar(&cfg.debugOverlay, "debug-overlay", false, "draw paths, nodes and simulation info over the world")
+	flag.IntVar(&cfg.headlessTicks, "headless", 0, "run N simulation ticks without a window, print a summary and exit")
+	flag.StringVar(&cfg.recordPath, "record", "", "record per-tick input to a replay file (written on exit)")
+	flag.StringVar(&cfg.replayPath, "replay", "", "play back a replay file instead of live input")
+	flag.BoolVar(&cfg.check, "check", false, "with -replay: run it headless and verify its expected outcome (exit code 1 on mismatch)")
+	flag.Parse()
+
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "seed" {
+			cfg.seedSet = true
+		}
+	})
+	if !cfg.seedSet {
+		cfg.seed = time.Now().UnixNano()
+	}
+
+	if windowed != "" {
+		w, h, err := parseWindowSize(windowed)
+		if err != nil {
+			return err
+		}
+		cfg.windowed = true
+		cfg.windowWidth, cfg.windowHeight = w, h
+	}
+	if cfg.check && cfg.replayPath == "" {
+		return fmt.Errorf("-check needs -replay")
+	}
+	assets = newAssetManager(cfg.assetsDir)
+	return nil
+}
+
+// parseWindowSize parses "WIDTHxHEIGHT".
+func parseWindowSize(s string) (int, int, error) {
+	ws, hs, ok := strings.Cut(strings.ToLower(s), "x")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid window size %q, expected WIDTHxHEIGHT", s)
+	}
+	w, werr := strconv.Atoi(ws)
+	h, herr := strconv.Atoi(hs)
+	if werr != nil || herr != nil || w <= 0 || h <= 0 {
+		return 0, 0, fmt.Errorf("invalid window size %q, expected WIDTHxHEIGHT", s)
+	}
+	return w, h, nil
+}
+
+// assetPath resolves a path like "import/tiles/x.png" against the assets directory.
+func assetPath(rel string) string {
+	return assets.loader.Path(rel)
+}
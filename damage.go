@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"image/color"
 	"math"
-	"math/rand"
 
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/text"
@@ -25,13 +24,14 @@ type DamageIndicator struct {
 var damageIndicators []*DamageIndicator
 
 func AddDamageIndicator(p pos, amount float32, crit bool) {
+	rnd := game.rng.stream(rngFX)
 	// Randomize initial small positional jitter so overlapping hits don't completely stack
-	p.float_x += (rand.Float32()*2 - 1) * 8 // +/-8 px jitter
-	p.float_y += (rand.Float32()*2 - 1) * 4 // slight vertical jitter
+	p.float_x += (rnd.Float32()*2 - 1) * 8 // +/-8 px jitter
+	p.float_y += (rnd.Float32()*2 - 1) * 4 // slight vertical jitter
 
 	// Random velocities: mostly upward, slight horizontal spread
-	vx := (rand.Float32()*2 - 1) * 60 // -60 .. +60 px/sec
-	vy := 70 + rand.Float32()*70      // 70 .. 140 base upward speed
+	vx := (rnd.Float32()*2 - 1) * 60 // -60 .. +60 px/sec
+	vy := 70 + rnd.Float32()*70      // 70 .. 140 base upward speed
 	// Crits fly a bit faster and higher
 	if crit {
 		vx *= 1.1
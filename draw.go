@@ -6,29 +6,13 @@ import (
 	"github.com/hajimehoshi/ebiten/v2"
 )
 
-var drawables []drawable
-
 type drawable interface {
 	draw(sceen *ebiten.Image)
 	Y() float32
-	giveId(id int)
-}
-
-func (c *character) giveId(id int) {
-	c.id = id
-}
-func (e *enemy) giveId(id int) {
-	e.id = id
-}
-func (t *tree) giveId(id int) {
-	t.id = id
 }
 
-func removeAtID(id int, d []drawable) []drawable {
-	var dr []drawable
-	dr = append(d[:id], d[id+1:]...)
-	return dr
-}
+// reused between frames by sortDrawables
+var drawOrder []entity
 
 func drawTile(screen, t *ebiten.Image, i, j int) {
 	op := &ebiten.DrawImageOptions{}
@@ -45,13 +29,12 @@ func drawTile(screen, t *ebiten.Image, i, j int) {
 }
 
 func (e *enemy) draw(screen *ebiten.Image) {
-	if e.dead {
+	// texture is only set once the simulation has stepped this enemy
+	if e.dead || e.texture == nil {
 		return
 	}
 	op := &ebiten.DrawImageOptions{}
 
-	e.todoEnemy()
-
 	scaleX := float64(screendivisor) / 18 * float64(game.camera.zoom)
 	scaleY := float64(screendivisor) / 18 * float64(game.camera.zoom)
 	op.GeoM.Scale(scaleX, scaleY)
@@ -62,10 +45,11 @@ func (e *enemy) draw(screen *ebiten.Image) {
 		op.ColorM = colorscale
 	}
 
-	// Positioning with respect to camera
+	// Positioning with respect to camera, interpolated between simulation steps
+	p := lerpPos(e.prevPos, e.pos, game.alpha)
 	op.GeoM.Translate(
-		float64(offsetsx(e.pos.float_x))-float64(screendivisor),
-		float64(offsetsy(e.pos.float_y))-float64(screendivisor),
+		float64(offsetsx(p.float_x))-float64(screendivisor),
+		float64(offsetsy(p.float_y))-float64(screendivisor),
 	)
 
 	// Draw the selected portion of the image onto the screen
@@ -85,18 +69,21 @@ func (t *tree) draw(screen *ebiten.Image) {
 }
 
 func (c *character) draw(screen *ebiten.Image) {
+	if c.texture == nil {
+		return
+	}
 	op := &ebiten.DrawImageOptions{}
 
-	c.todoCharacter()
-
 	originalWidth, originalHeight := c.texture.Size()
 	scaleX := float64(screendivisor) / 18 * float64(game.camera.zoom)
 	scaleY := float64(screendivisor) / 18 * float64(game.camera.zoom)
 	op.GeoM.Scale(scaleX, scaleY)
 
-	// Calculate the centered position based on screen dimensions and zoom level
-	centerX := (float64(screenWidth) / 2) - (float64(originalWidth) * scaleX / 2)
-	centerY := (float64(screenHeight) / 2) - (float64(originalHeight) * scaleY / 2)
+	// Center the sprite on the interpolated position (the camera follows the
+	// player, so this ends up in the middle of the screen)
+	p := lerpPos(c.prevPos, c.pos, game.alpha)
+	centerX := float64(offsetsx(p.float_x)) - (float64(originalWidth) * scaleX / 2)
+	centerY := float64(offsetsy(p.float_y)) - (float64(originalHeight) * scaleY / 2)
 	op.GeoM.Translate(centerX, centerY)
 
 	screen.DrawImage(c.texture, op)
@@ -120,8 +107,11 @@ func (e *enemy) Y() float32 {
 	return e.pos.float_y + 25
 }
 
-func sortDrawables() {
-	sort.Slice(drawables, func(a, b int) bool {
-		return drawables[a].Y() < drawables[b].Y()
+// sortDrawables returns every entity ordered back to front.
+func sortDrawables() []entity {
+	drawOrder = append(drawOrder[:0], game.entities.all...)
+	sort.SliceStable(drawOrder, func(a, b int) bool {
+		return drawOrder[a].Y() < drawOrder[b].Y()
 	})
+	return drawOrder
 }
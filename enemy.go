@@ -2,7 +2,6 @@ package main
 
 import (
 	"math"
-	"math/rand"
 
 	"github.com/hajimehoshi/ebiten/v2"
 )
@@ -14,12 +13,18 @@ const (
 	KNOCKBACK_BASE_STRENGTH = 520  // initial velocity magnitude applied on hit
 	KNOCKBACK_MAX_STACK     = 780  // cap on stacked knockback velocity
 	KNOCKBACK_DURATION      = 0.18 // time (s) during which knockback overrides AI movement
+	// a patrol / path leg that hasn't got LEG_PROGRESS px closer to its goal
+	// for LEG_STALL_TIME seconds is given up (walled off, stuck in a pack)
+	LEG_STALL_TIME = 4
+	LEG_PROGRESS   = 5
 )
 
 type enemy struct {
-	pos     pos
+	pos pos
+	// position at the start of the current simulation step (render interpolation)
+	prevPos pos
 	texture *ebiten.Image
-	id      int
+	id      entityID
 
 	speed    float32
 	velocity float32
@@ -35,6 +40,16 @@ type enemy struct {
 	routeIndex int
 	chasing    bool
 
+	// way around obstacles while patrolling / chasing / returning home, see gridpath.go
+	gridRoute      []pos
+	gridRouteIndex int
+	gridRepath     float64
+
+	// closest the enemy got to the current leg's goal & for how long it
+	// hasn't got closer, see stalled
+	legBest  float32
+	legStall float64
+
 	sleeping   bool
 	sinceSleep float64
 
@@ -61,8 +76,8 @@ type enemy struct {
 
 func enemiesInRange(pos pos, distance float32) []*enemy {
 	var es []*enemy
-	for i := 0; i < len(game.currentmap.enemies); i++ {
-		enemy := game.currentmap.enemies[i]
+	for i := 0; i < len(game.entities.enemies); i++ {
+		enemy := game.entities.enemies[i]
 		if enemy == nil || enemy.dead || enemy.hp <= 0 {
 			continue // skip dead / removed enemies
 		}
@@ -73,15 +88,26 @@ func enemiesInRange(pos pos, distance float32) []*enemy {
 	return es
 }
 
-func createEnemy(pos pos) {
+// createEnemy adds an enemy at pos and publishes EnemySpawned. spawner is
+// its index into spawners (home & leash come from there), -1 for enemies
+// placed without one.
+func createEnemy(pos pos, spawner int) *enemy {
 	var e enemy
 	e.pos = pos
+	e.prevPos = pos
 	e.speed = ENEMYNORMALSPEED
 	e.hp = 60
-	e.offsetForAnimation = rand.Intn(5)
-	e.spawnerIndex = -1
-	game.currentmap.enemies = append(game.currentmap.enemies, &e)
-	drawables = append(drawables, &e)
+	e.offsetForAnimation = game.rng.stream(rngFX).Intn(5)
+	e.spawnerIndex = spawner
+	if spawner >= 0 {
+		rs := spawners[spawner]
+		e.homePos = createPos(rs.data.Pos.X, rs.data.Pos.Y)
+		e.leashRadius = rs.data.Radius
+		rs.alive[&e] = struct{}{}
+	}
+	game.entities.add(&e)
+	game.events.enemySpawned.publish(EnemySpawned{Enemy: &e, Spawner: spawner})
+	return &e
 }
 
 func (e *enemy) todoEnemy() {
@@ -117,20 +143,9 @@ func (e *enemy) updateAnimation() {
 	e.animationState = 0
 }
 
+// moveTowards steers the enemy towards target at full speed, see steering.go.
 func (e *enemy) moveTowards(target pos) {
-	e.animationState = 1
-	dx := target.float_x - e.pos.float_x
-	dy := target.float_y - e.pos.float_y
-	length := float32(math.Sqrt(float64(dx*dx + dy*dy)))
-
-	if length > 0 {
-		dx /= length
-		dy /= length
-
-		e.pos.float_x += dx * e.speed * float32(game.deltatime)
-		e.pos.float_y += dy * e.speed * float32(game.deltatime)
-	}
-
+	e.seek(target)
 }
 
 func (e *enemy) patrol() {
@@ -155,27 +170,77 @@ func (e *enemy) patrol() {
 		if len(e.route) == 0 { // fallback to original global pathing if constraints fail
 			e.route = findShortestPathPositions(findClosestNode(e.pos).id, findClosestNode(goalPos).id)
 		}
-		e.target = e.route[e.routeIndex]
+		if len(e.route) == 0 {
+			// no way there (no nodes or a cut off node), try again later
+			e.inPatrol = false
+			e.sinceSleep = 0
+			return
+		}
+		e.target = nearestClear(e.route[e.routeIndex])
+		e.endLeg()
 
 	} else {
-		e.moveTowards(e.target)
+		e.navigateTo(e.target)
+		if e.stalled(Distance(e.target, e.pos)) {
+			// can't get there, sleep & pick another route
+			e.inPatrol = false
+			e.sinceSleep = 0
+			e.endLeg()
+			return
+		}
 	}
 
+	// targets are already as close to their node as a body gets
 	if Distance(e.target, e.pos) < 30 {
+		e.endLeg()
 		if e.routeIndex == len(e.route)-1 {
 			e.inPatrol = false
 			e.sinceSleep = 0
 			e.routeIndex = 0
 		} else {
 			e.routeIndex++
-			e.target = e.route[e.routeIndex]
+			e.target = nearestClear(e.route[e.routeIndex])
 		}
 	}
 }
 
+// stalled records the distance d left to the current leg's goal and reports
+// whether it hasn't shrunk by LEG_PROGRESS px for LEG_STALL_TIME seconds.
+func (e *enemy) stalled(d float32) bool {
+	if e.legBest <= 0 || d < e.legBest-LEG_PROGRESS {
+		e.legBest, e.legStall = d, 0
+		return false
+	}
+	e.legStall += game.deltatime
+	return e.legStall > LEG_STALL_TIME
+}
+
+// endLeg forgets the progress of the current leg, the next one starts fresh.
+func (e *enemy) endLeg() {
+	e.legBest, e.legStall = 0, 0
+}
+
 func (e *enemy) chase() {
 	nearestP := nearestCharacter(e.pos)
-	e.moveTowards(nearestP.pos)
+	if nearestP == nil {
+		return
+	}
+	if lineClear(e.pos, nearestP.pos) {
+		e.gridRoute = nil
+		e.gridRepath = 0
+		e.arrive(nearestP.pos)
+		return
+	}
+	// around the obstacles along the player's flow field, shared by every
+	// enemy chasing that player
+	if wp, ok := game.currentmap.flowField(nearestP).next(e.pos); ok {
+		e.gridRoute = nil
+		e.gridRepath = 0
+		e.moveTowards(wp)
+		return
+	}
+	// too far away for the field (or no way there)
+	e.navigateTo(nearestP.pos)
 }
 
 func (e *enemy) checkCollision(posA, posB pos) bool {
@@ -188,6 +253,7 @@ func (e *enemy) checkCollision(posA, posB pos) bool {
 func (e *enemy) hurt(c *character) {
 	e.hp -= 0.3
 	c.hp -= 0.1
+	game.events.playerHurt.publish(PlayerHurt{Player: c, Source: e, Amount: 0.1})
 }
 
 func (e *enemy) checkHp() {
@@ -197,23 +263,11 @@ func (e *enemy) checkHp() {
 	if e.hp <= 0 {
 		// Mark dead so we don't process logic any further
 		e.dead = true
-		// Inform spawner system if applicable
-		removeEnemyFromSpawner(e)
-
-		// Remove from enemies slice
-		for i, en := range game.currentmap.enemies {
-			if en == e {
-				game.currentmap.enemies = append(game.currentmap.enemies[:i], game.currentmap.enemies[i+1:]...)
-				break
-			}
-		}
-		// Remove from drawables slice
-		for i, d := range drawables {
-			if de, ok := d.(*enemy); ok && de == e {
-				drawables = append(drawables[:i], drawables[i+1:]...)
-				break
-			}
-		}
+		// Spawners etc. clean up in their subscribers
+		game.events.enemyKilled.publish(EnemyKilled{Enemy: e})
+
+		// Leaves the registry at the end of the step
+		game.entities.remove(e.id)
 	}
 }
 
@@ -253,26 +307,24 @@ func (e *enemy) updateState() {
 		if distHome > e.leashRadius*4 {
 			// run back towards home at alert speed, stop chase
 			e.chasing = false
-			e.animationState = 1
-			if distHome > 0 {
-				dx /= distHome; dy /= distHome
-			}
-			retSpeed := float32(ENEMYALLERTSPEED)
-			e.pos.float_x -= dx * retSpeed * float32(game.deltatime)
-			e.pos.float_y -= dy * retSpeed * float32(game.deltatime)
+			e.speed = ENEMYALLERTSPEED
+			e.navigateTo(e.homePos)
 			return
 		}
 	}
 
-	if Distance(e.pos, player.pos) > 100 && !e.chasing {
+	if player == nil || (Distance(e.pos, player.pos) > 100 && !e.chasing) {
 		e.speed = ENEMYNORMALSPEED
-		nearestP, distanceToNearest := findClosestPointOnPaths(e.pos)
+		nearestP, _ := findClosestPointOnPaths(e.pos)
 		switch e.aiState {
 		case 0: // move towards nearest path
-			if distanceToNearest > 40 {
-				e.moveTowards(nearestP)
+			goal := nearestClear(nearestP)
+			if d := Distance(e.pos, goal); d > 40 && !e.stalled(d) {
+				e.navigateTo(goal)
 			} else {
+				// there, or as close as it gets: patrol from here
 				e.aiState = 1
+				e.endLeg()
 			}
 		case 1:
 			if e.sinceSleep > 1 {
@@ -283,8 +335,8 @@ func (e *enemy) updateState() {
 		}
 	} else {
 
-		for i := 0; i < len(game.currentmap.players); i++ {
-			c := game.currentmap.players[i]
+		for i := 0; i < len(game.entities.players); i++ {
+			c := game.entities.players[i]
 			if checkCollision(e.pos, c.pos) {
 				e.hurt(c)
 			}
@@ -293,6 +345,7 @@ func (e *enemy) updateState() {
 		e.chasing = true
 		e.speed = ENEMYALLERTSPEED
 		e.inPatrol = false
+		e.endLeg()
 		// Allow chase up to 4x leash if applicable; otherwise normal
 		if e.spawnerIndex >= 0 && e.leashRadius > 0 {
 			// Only chase if within 4x leash from home; else will be handled by early return above
@@ -312,16 +365,9 @@ func (e *enemy) updateState() {
 		dy := e.pos.float_y - e.homePos.float_y
 		dist := float32(math.Sqrt(float64(dx*dx + dy*dy)))
 		if dist > e.leashRadius*1.1 { // allow small overflow then pull back
-			// override movement: move towards home rapidly
-			// simple direct return
-			e.animationState = 1
-			if dist > 0 {
-				dx /= dist
-				dy /= dist
-			}
-			retSpeed := float32(ENEMYALLERTSPEED)
-			e.pos.float_x -= dx * retSpeed * float32(game.deltatime)
-			e.pos.float_y -= dy * retSpeed * float32(game.deltatime)
+			// override movement: head home at alert speed, around walls
+			e.speed = ENEMYALLERTSPEED
+			e.navigateTo(e.homePos)
 			// stop chasing once outside leash
 			e.chasing = false
 		}
@@ -0,0 +1,148 @@
+package main
+
+// entityID is a stable handle for something living in the world. IDs are never
+// reused within a session, so a stale handle simply stops resolving.
+type entityID uint32
+
+// entity is anything the registry tracks: players, enemies, NPCs, trees.
+type entity interface {
+	drawable
+	ID() entityID
+	setID(id entityID)
+}
+
+func (c *character) ID() entityID      { return c.id }
+func (c *character) setID(id entityID) { c.id = id }
+func (e *enemy) ID() entityID          { return e.id }
+func (e *enemy) setID(id entityID)     { e.id = id }
+func (n *npc) ID() entityID            { return n.id }
+func (n *npc) setID(id entityID)       { n.id = id }
+func (t *tree) ID() entityID           { return t.id }
+func (t *tree) setID(id entityID)      { t.id = id }
+
+// entityRegistry owns every entity of the current map.
+//
+// Removal is deferred: remove only schedules it and flush (called at the end
+// of every simulation step) applies it, so systems can destroy entities while
+// iterating over the typed lists below.
+type entityRegistry struct {
+	next    entityID
+	byID    map[entityID]entity
+	all     []entity
+	pending []entityID
+
+	// typed views, read-only outside of the registry
+	players []*character
+	enemies []*enemy
+	npcs    []*npc
+	trees   []*tree
+}
+
+// add registers e, assigns its ID and returns it.
+func (r *entityRegistry) add(e entity) entityID {
+	if r.byID == nil {
+		r.byID = make(map[entityID]entity)
+	}
+	r.next++
+	id := r.next
+	e.setID(id)
+	r.byID[id] = e
+	r.all = append(r.all, e)
+
+	switch v := e.(type) {
+	case *character:
+		r.players = append(r.players, v)
+	case *enemy:
+		r.enemies = append(r.enemies, v)
+	case *npc:
+		r.npcs = append(r.npcs, v)
+	case *tree:
+		r.trees = append(r.trees, v)
+	}
+	return id
+}
+
+// remove schedules the entity for removal at the next flush.
+func (r *entityRegistry) remove(id entityID) {
+	if _, ok := r.byID[id]; !ok {
+		return
+	}
+	for _, p := range r.pending {
+		if p == id {
+			return
+		}
+	}
+	r.pending = append(r.pending, id)
+}
+
+// get resolves a handle, nil if the entity is gone.
+func (r *entityRegistry) get(id entityID) entity {
+	return r.byID[id]
+}
+
+// alive reports whether the entity exists and isn't scheduled for removal.
+func (r *entityRegistry) alive(id entityID) bool {
+	if _, ok := r.byID[id]; !ok {
+		return false
+	}
+	for _, p := range r.pending {
+		if p == id {
+			return false
+		}
+	}
+	return true
+}
+
+// flush applies pending removals.
+func (r *entityRegistry) flush() {
+	if len(r.pending) == 0 {
+		return
+	}
+	gone := make(map[entityID]bool, len(r.pending))
+	for _, id := range r.pending {
+		gone[id] = true
+		delete(r.byID, id)
+	}
+	r.pending = r.pending[:0]
+
+	r.all = withoutEntities(r.all, gone)
+	r.players = withoutEntities(r.players, gone)
+	r.enemies = withoutEntities(r.enemies, gone)
+	r.npcs = withoutEntities(r.npcs, gone)
+	r.trees = withoutEntities(r.trees, gone)
+}
+
+// clear drops every entity (used when a map is unloaded).
+func (r *entityRegistry) clear() {
+	next := r.next
+	*r = entityRegistry{next: next}
+}
+
+// adopt registers an entity that keeps its ID, e.g. a player carried over
+// to a new map after clear.
+func (r *entityRegistry) adopt(e entity) {
+	if r.byID == nil {
+		r.byID = make(map[entityID]entity)
+	}
+	r.byID[e.ID()] = e
+	r.all = append(r.all, e)
+	if c, ok := e.(*character); ok {
+		r.players = append(r.players, c)
+	}
+}
+
+// withoutEntities filters list in place, dropping the IDs in gone.
+func withoutEntities[T entity](list []T, gone map[entityID]bool) []T {
+	out := list[:0]
+	for _, e := range list {
+		if !gone[e.ID()] {
+			out = append(out, e)
+		}
+	}
+	// don't keep dropped pointers alive in the tail
+	for i := len(out); i < len(list); i++ {
+		var zero T
+		list[i] = zero
+	}
+	return out
+}
@@ -0,0 +1,101 @@
+package main
+
+// Game events. Gameplay code publishes what happened, anything else (damage
+// numbers, knockback, spawners, and later quests, audio, achievements...)
+// subscribes instead of being called directly from combat code.
+//
+// Handlers run synchronously, in subscription order, inside the simulation
+// step that published the event, so they must stay deterministic.
+
+type EnemyDamaged struct {
+	Enemy  *enemy
+	Source *character // nil if not caused by a player
+	Amount float32
+	Crit   bool
+}
+
+type EnemyKilled struct {
+	Enemy *enemy
+}
+
+type PlayerHurt struct {
+	Player *character
+	Source *enemy
+	Amount float32
+}
+
+type DialogueStarted struct {
+	NPC    *npc
+	Player *character
+}
+
+type DialogueEnded struct {
+	NPC *npc
+	// true when the last line was read, false when cancelled
+	Finished bool
+}
+
+type EnemySpawned struct {
+	Enemy *enemy
+	// index into spawners, -1 for enemies placed without a spawner
+	Spawner int
+}
+
+// TileEntered fires when a player's position moves onto a new map tile.
+type TileEntered struct {
+	Player *character
+	X, Y   int
+	Tile   int
+}
+
+// MapChanged fires after a portal moved the players, From and To are map
+// files (the same one for portals within a map).
+type MapChanged struct {
+	From, To string
+	Spawn    string
+}
+
+// topic is a list of handlers for one event type.
+type topic[E any] struct {
+	handlers []func(E)
+}
+
+func (t *topic[E]) subscribe(fn func(E)) {
+	t.handlers = append(t.handlers, fn)
+}
+
+func (t *topic[E]) publish(ev E) {
+	for _, fn := range t.handlers {
+		fn(ev)
+	}
+}
+
+type eventBus struct {
+	enemyDamaged    topic[EnemyDamaged]
+	enemyKilled     topic[EnemyKilled]
+	playerHurt      topic[PlayerHurt]
+	dialogueStarted topic[DialogueStarted]
+	dialogueEnded   topic[DialogueEnded]
+	enemySpawned    topic[EnemySpawned]
+	tileEntered     topic[TileEntered]
+	mapChanged      topic[MapChanged]
+}
+
+// registerCoreSubscribers wires up the game's own reactions to events.
+func registerCoreSubscribers() {
+	game.events.enemyDamaged.subscribe(func(ev EnemyDamaged) {
+		if ev.Source != nil {
+			applyKnockback(ev.Enemy, ev.Source, KNOCKBACK_BASE_STRENGTH)
+		}
+		AddDamageIndicator(ev.Enemy.pos, ev.Amount, ev.Crit)
+	})
+	game.events.playerHurt.subscribe(func(ev PlayerHurt) {
+		// hazard tiles hurt every tick, only shake for hits
+		if ev.Source != nil {
+			game.camera.shake(4, 0.15)
+		}
+	})
+	game.events.enemyKilled.subscribe(func(ev EnemyKilled) {
+		removeEnemyFromSpawner(ev.Enemy)
+	})
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"container/heap"
+	"math"
+)
+
+// Flow fields for chasing. Every player has one: the walking distance from
+// each tile around the player to the player's tile (Dijkstra over the
+// walkable tiles, same moves as findGridPath). A chasing enemy just steps
+// to the neighbouring tile with the smallest distance, so however many
+// enemies chase one player it's one search per tile the player crosses
+// instead of one per enemy.
+const (
+	// tiles around the player the field covers (a 65x65 window)
+	FLOW_FIELD_RADIUS = 32
+	// chasers aim up to this many tiles ahead along the field when they
+	// can walk there straight, so they don't zigzag from tile to tile
+	FLOW_LOOKAHEAD = 4
+)
+
+type flowField struct {
+	// the player's tile the field leads to
+	goalX, goalY int
+	// top left tile of the window and its side length
+	originX, originY, size int
+	// walking distance to the goal in tiles, +Inf if there's no way
+	dist []float32
+}
+
+// flowField returns the flow field leading to a player, recomputed when the
+// player has moved to another tile since it was last asked for. The fields
+// belong to the map so loading another one drops them.
+func (m *gamemap) flowField(c *character) *flowField {
+	x, y := bodyTile(c.pos)
+	if f := m.flows[c.id]; f != nil && f.goalX == x && f.goalY == y {
+		return f
+	}
+	if m.flows == nil {
+		m.flows = make(map[entityID]*flowField)
+	}
+	f := buildFlowField(x, y)
+	m.flows[c.id] = f
+	return f
+}
+
+// dropFlowFields forgets the fields of players that have left the world.
+func (m *gamemap) dropFlowFields() {
+	for id := range m.flows {
+		if !game.entities.alive(id) {
+			delete(m.flows, id)
+		}
+	}
+}
+
+func buildFlowField(gx, gy int) *flowField {
+	size := 2*FLOW_FIELD_RADIUS + 1
+	f := &flowField{
+		goalX:   gx,
+		goalY:   gy,
+		originX: gx - FLOW_FIELD_RADIUS,
+		originY: gy - FLOW_FIELD_RADIUS,
+		size:    size,
+		dist:    make([]float32, size*size),
+	}
+	for i := range f.dist {
+		f.dist[i] = float32(math.Inf(1))
+	}
+	start, ok := f.index(gx, gy)
+	if !ok || !tileWalkable(gx, gy) {
+		return f // the player is off the map or in a wall, nothing leads there
+	}
+	f.dist[start] = 0
+
+	open := &navQueue{{node: start, priority: 0}}
+	for open.Len() > 0 {
+		it := heap.Pop(open).(navItem)
+		if it.priority > f.dist[it.node] {
+			continue // stale entry
+		}
+		cx, cy := f.originX+it.node%size, f.originY+it.node/size
+		for _, d := range gridDirs {
+			nx, ny := cx+d[0], cy+d[1]
+			next, ok := f.index(nx, ny)
+			if !ok || !tileWalkable(nx, ny) {
+				continue
+			}
+			step := float32(1)
+			if d[0] != 0 && d[1] != 0 {
+				if !tileWalkable(cx+d[0], cy) || !tileWalkable(cx, cy+d[1]) {
+					continue
+				}
+				step = math.Sqrt2
+			}
+			if c := it.priority + step; c < f.dist[next] {
+				f.dist[next] = c
+				heap.Push(open, navItem{node: next, priority: c})
+			}
+		}
+	}
+	return f
+}
+
+// index returns the position of a tile in dist, false outside the window.
+func (f *flowField) index(x, y int) (int, bool) {
+	x -= f.originX
+	y -= f.originY
+	if x < 0 || y < 0 || x >= f.size || y >= f.size {
+		return 0, false
+	}
+	return y*f.size + x, true
+}
+
+// distance returns the walking distance from a tile to the goal, +Inf if
+// unknown.
+func (f *flowField) distance(x, y int) float32 {
+	if i, ok := f.index(x, y); ok {
+		return f.dist[i]
+	}
+	return float32(math.Inf(1))
+}
+
+// downhill returns the neighbour of a tile that is closest to the goal,
+// false if none is closer than the tile itself.
+func (f *flowField) downhill(x, y int) (int, int, bool) {
+	bx, by, best := x, y, f.distance(x, y)
+	for _, d := range gridDirs {
+		nx, ny := x+d[0], y+d[1]
+		if d[0] != 0 && d[1] != 0 && (!tileWalkable(x+d[0], y) || !tileWalkable(x, y+d[1])) {
+			continue
+		}
+		if dn := f.distance(nx, ny); dn < best {
+			bx, by, best = nx, ny, dn
+		}
+	}
+	return bx, by, bx != x || by != y
+}
+
+// next returns where a body at p should head to get to the goal, false if
+// p is outside the field or can't reach the goal.
+func (f *flowField) next(p pos) (pos, bool) {
+	x, y := bodyTile(p)
+	if math.IsInf(float64(f.distance(x, y)), 1) {
+		return pos{}, false
+	}
+	nx, ny, ok := f.downhill(x, y)
+	if !ok {
+		return pos{}, false // on the goal tile
+	}
+	target := createPos(float32(nx)*screendivisor, float32(ny)*screendivisor)
+	if !lineClear(p, target) {
+		// the body sticks out of its tile into the corner, line up first
+		return createPos(float32(x)*screendivisor, float32(y)*screendivisor), true
+	}
+	x, y = nx, ny
+	for i := 1; i < FLOW_LOOKAHEAD; i++ {
+		ax, ay, ok := f.downhill(x, y)
+		if !ok {
+			break
+		}
+		ahead := createPos(float32(ax)*screendivisor, float32(ay)*screendivisor)
+		if !lineClear(p, ahead) {
+			break
+		}
+		x, y, target = ax, ay, ahead
+	}
+	return target, true
+}
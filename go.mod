@@ -5,6 +5,7 @@ go 1.23.2
 require (
 	github.com/hajimehoshi/ebiten/v2 v2.8.1
 	golang.org/x/image v0.20.0
+	rpg/assetio v0.0.0-00010101000000-000000000000
 	rpg/mapio v0.0.0-00010101000000-000000000000
 )
 
@@ -19,3 +20,5 @@ require (
 )
 
 replace rpg/mapio => ./mapio
+
+replace rpg/assetio => ./assetio
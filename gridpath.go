@@ -0,0 +1,200 @@
+package main
+
+import (
+	"container/heap"
+	"math"
+)
+
+// Tile grid pathfinding, for moving where the node/path network doesn't go
+// (chasing a player, running back to the spawner). Positions are top left
+// corners of a screendivisor sized body, like enemy.pos.
+const (
+	// the search gives up after expanding this many tiles (~ a 60x60 area)
+	GRID_PATH_MAX_NODES = 3600
+	// how often a blocked enemy looks for a new way (seconds)
+	GRID_REPATH_TIME = 0.5
+	// how far (tiles) nearestClear looks for a free spot
+	NEAREST_CLEAR_RADIUS = 4
+)
+
+// tileWalkable reports whether the tile is on the map and not solid.
+func tileWalkable(x, y int) bool {
+	return x >= 0 && y >= 0 && x < game.currentmap.width && y < game.currentmap.height && !solidTile(y, x)
+}
+
+// bodyClear reports whether a body at p overlaps no solid tile.
+func bodyClear(p pos) bool {
+	if p.float_x < 0 || p.float_y < 0 {
+		return false
+	}
+	x0, y0 := ptid(p)
+	x1, y1 := ptid(createPos(p.float_x+screendivisor-1, p.float_y+screendivisor-1))
+	for y := y0; y <= y1; y++ {
+		for x := x0; x <= x1; x++ {
+			if !tileWalkable(x, y) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// nearestClear returns the clear body position closest to p: p itself when
+// nothing is in the way, else the nearest free tile within
+// NEAREST_CLEAR_RADIUS tiles. Nodes sitting on or against a wall can only
+// be reached that far. p when there's no free tile around.
+func nearestClear(p pos) pos {
+	if bodyClear(p) {
+		return p
+	}
+	px, py := bodyTile(p)
+	best, bestD := p, float32(math.MaxFloat32)
+	for y := py - NEAREST_CLEAR_RADIUS; y <= py+NEAREST_CLEAR_RADIUS; y++ {
+		for x := px - NEAREST_CLEAR_RADIUS; x <= px+NEAREST_CLEAR_RADIUS; x++ {
+			if !tileWalkable(x, y) {
+				continue
+			}
+			c := createPos(float32(x)*screendivisor, float32(y)*screendivisor)
+			if d := Distance(p, c); d < bestD {
+				best, bestD = c, d
+			}
+		}
+	}
+	return best
+}
+
+// bodyTile returns the tile under the centre of a body at p.
+func bodyTile(p pos) (int, int) {
+	return ptid(createPos(p.float_x+screendivisor/2, p.float_y+screendivisor/2))
+}
+
+// lineClear reports whether a body can move straight from a to b.
+func lineClear(a, b pos) bool {
+	steps := int(Distance(a, b)/(screendivisor/3)) + 1
+	for i := 0; i <= steps; i++ {
+		t := float32(i) / float32(steps)
+		if !bodyClear(createPos(a.float_x+t*(b.float_x-a.float_x), a.float_y+t*(b.float_y-a.float_y))) {
+			return false
+		}
+	}
+	return true
+}
+
+var gridDirs = [8][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}, {1, 1}, {1, -1}, {-1, 1}, {-1, -1}}
+
+// findGridPath runs A* over the tiles (8 directions, diagonals only when
+// both tiles beside them are free so corners aren't cut) and returns the
+// waypoints from `from` to `to`, nil if there's no way (or it's too far).
+func findGridPath(from, to pos) []pos {
+	w := game.currentmap.width
+	// search from the tiles under the body centres
+	sx, sy := bodyTile(from)
+	gx, gy := bodyTile(to)
+	if !tileWalkable(gx, gy) || sx < 0 || sy < 0 || sx >= w || sy >= game.currentmap.height {
+		return nil
+	}
+	start, goal := sy*w+sx, gy*w+gx
+
+	// octile distance
+	h := func(x, y int) float32 {
+		dx, dy := float32(abs(x-gx)), float32(abs(y-gy))
+		return max(dx, dy) + (math.Sqrt2-1)*min(dx, dy)
+	}
+	costSoFar := map[int]float32{start: 0}
+	cameFrom := map[int]int{start: -1}
+	closed := map[int]bool{}
+
+	open := &navQueue{{node: start, priority: h(sx, sy)}}
+	for open.Len() > 0 && len(closed) < GRID_PATH_MAX_NODES {
+		cur := heap.Pop(open).(navItem).node
+		if closed[cur] {
+			continue
+		}
+		closed[cur] = true
+		if cur == goal {
+			return gridWaypoints(from, cameFrom, goal)
+		}
+		cx, cy := cur%w, cur/w
+		for _, d := range gridDirs {
+			nx, ny := cx+d[0], cy+d[1]
+			if !tileWalkable(nx, ny) {
+				continue
+			}
+			step := float32(1)
+			if d[0] != 0 && d[1] != 0 {
+				if !tileWalkable(cx+d[0], cy) || !tileWalkable(cx, cy+d[1]) {
+					continue // would cut the corner
+				}
+				step = math.Sqrt2
+			}
+			next := ny*w + nx
+			if closed[next] {
+				continue
+			}
+			c := costSoFar[cur] + step
+			if old, ok := costSoFar[next]; !ok || c < old {
+				costSoFar[next] = c
+				cameFrom[next] = cur
+				heap.Push(open, navItem{node: next, priority: c + h(nx, ny)})
+			}
+		}
+	}
+	return nil
+}
+
+// gridWaypoints turns the found tiles into body positions, skipping the
+// ones that can be cut straight across.
+func gridWaypoints(from pos, cameFrom map[int]int, goal int) []pos {
+	w := game.currentmap.width
+	var tiles []pos
+	for i := goal; i >= 0; i = cameFrom[i] {
+		tiles = append(tiles, createPos(float32(i%w)*screendivisor, float32(i/w)*screendivisor))
+	}
+	// tiles is goal -> start, the start tile itself isn't needed unless
+	// it's the goal too (the body sticks out of it into a wall)
+	if len(tiles) > 1 {
+		tiles = tiles[:len(tiles)-1]
+	}
+
+	var route []pos
+	anchor := from
+	for i := len(tiles) - 1; i >= 0; i-- {
+		if i > 0 && lineClear(anchor, tiles[i-1]) {
+			continue
+		}
+		route = append(route, tiles[i])
+		anchor = tiles[i]
+	}
+	return route
+}
+
+// navigateTo moves the enemy towards goal: straight when nothing is in the
+// way, along a tile path around the obstacles otherwise. Without any way
+// there it waits.
+func (e *enemy) navigateTo(goal pos) {
+	if lineClear(e.pos, goal) {
+		e.gridRoute = nil
+		e.gridRepath = 0 // search right away once blocked
+		e.moveTowards(goal)
+		return
+	}
+	e.gridRepath -= game.deltatime
+	if e.gridRepath <= 0 {
+		e.gridRoute = findGridPath(e.pos, goal)
+		e.gridRouteIndex = 0
+		e.gridRepath = GRID_REPATH_TIME
+	}
+	if e.gridRouteIndex >= len(e.gridRoute) {
+		e.animationState = 0
+		return
+	}
+	wp := e.gridRoute[e.gridRouteIndex]
+	if Distance(e.pos, wp) <= e.speed*float32(game.deltatime) {
+		// don't overshoot, corners are tight
+		e.pos = wp
+		e.gridRouteIndex++
+		e.animationState = 1
+		return
+	}
+	e.moveTowards(wp)
+}
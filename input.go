@@ -0,0 +1,60 @@
+package main
+
+import (
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// inputButton is one logical control the simulation reacts to.
+type inputButton uint16
+
+const (
+	btnUp inputButton = 1 << iota
+	btnDown
+	btnLeft
+	btnRight
+	btnDash
+	btnAttack   // left mouse
+	btnInteract // E
+	btnAdvance  // Space / Enter (advance dialogue)
+	btnCancel   // Escape
+)
+
+// inputState is a snapshot of the controls for one simulation step.
+//
+// Gameplay code never asks ebiten about keys directly, it only reads this
+// struct, so a headless runner or a bot can drive the game by filling it in.
+type inputState struct {
+	buttons inputButton
+	// mouse wheel delta (vertical) since the previous snapshot
+	wheel float64
+}
+
+func (in inputState) held(b inputButton) bool {
+	return in.buttons&b != 0
+}
+
+// pollInput samples the current ebiten keyboard & mouse state.
+func pollInput() inputState {
+	var in inputState
+	keys := []struct {
+		pressed bool
+		btn     inputButton
+	}{
+		{ebiten.IsKeyPressed(ebiten.KeyW), btnUp},
+		{ebiten.IsKeyPressed(ebiten.KeyS), btnDown},
+		{ebiten.IsKeyPressed(ebiten.KeyA), btnLeft},
+		{ebiten.IsKeyPressed(ebiten.KeyD), btnRight},
+		{ebiten.IsKeyPressed(ebiten.KeyShift), btnDash},
+		{ebiten.IsMouseButtonPressed(ebiten.MouseButton0), btnAttack},
+		{ebiten.IsKeyPressed(ebiten.KeyE), btnInteract},
+		{ebiten.IsKeyPressed(ebiten.KeySpace) || ebiten.IsKeyPressed(ebiten.KeyEnter), btnAdvance},
+		{ebiten.IsKeyPressed(ebiten.KeyEscape), btnCancel},
+	}
+	for _, k := range keys {
+		if k.pressed {
+			in.buttons |= k.btn
+		}
+	}
+	_, in.wheel = ebiten.Wheel()
+	return in
+}
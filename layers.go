@@ -0,0 +1,63 @@
+package main
+
+import (
+	"rpg/mapio"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// drawChunkLayer draws one layer (ground, detail or overlay) of the chunks
+// on screen, only the visible tiles.
+func drawChunkLayer(screen *ebiten.Image, layer string) {
+	cc := &game.currentmap.chunks
+	if cc.source == nil {
+		return
+	}
+	x0, y0, x1, y1 := viewTiles(game.camera.renderPos)
+	cx0, cy0 := mapio.ChunkOf(x0, y0, cc.size)
+	cx1, cy1 := mapio.ChunkOf(x1, y1, cc.size)
+	for cy := cy0; cy <= cy1; cy++ {
+		for cx := cx0; cx <= cx1; cx++ {
+			c := cc.chunk(cx, cy)
+			if c == nil {
+				continue
+			}
+			if !c.textured {
+				c.buildTextures()
+			}
+			var tex [][]*ebiten.Image
+			switch layer {
+			case mapio.LayerGround:
+				tex = c.ground
+			case mapio.LayerDetail:
+				tex = c.detail
+			case mapio.LayerOverlay:
+				tex = c.overlay
+			}
+			ox, oy := c.Origin()
+			for y := range tex {
+				if oy+y < y0 || oy+y > y1 {
+					continue
+				}
+				for x, t := range tex[y] {
+					if t != nil && ox+x >= x0 && ox+x <= x1 {
+						drawTile(screen, t, oy+y, ox+x)
+					}
+				}
+			}
+		}
+	}
+}
+
+// solidTile reports whether the tile blocks movement: the collision layer
+// decides if it says so, otherwise the ground tile's definition.
+func solidTile(y, x int) bool {
+	switch layerTile(mapio.LayerCollision, y, x) {
+	case mapio.CollisionBlocked:
+		return true
+	case mapio.CollisionWalkable:
+		return false
+	}
+	def := tileDefAt(y, x)
+	return def != nil && def.Solid
+}
@@ -3,11 +3,9 @@ package main
 import (
 	"bytes"
 	"fmt"
-	"image/color"
 	"io"
 	"log"
 	"math"
-	"math/rand"
 	"os"
 	"time"
 
@@ -18,15 +16,13 @@ import (
 
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
-	"github.com/hajimehoshi/ebiten/v2/inpututil"
-	"github.com/hajimehoshi/ebiten/v2/vector"
 )
 
 // Screen sizes
 var (
-	width, height = ebiten.Monitor().Size()
-	screenWidth   = float32(width)
-	screenHeight  = float32(height)
+	width, height int
+	screenWidth   float32
+	screenHeight  float32
 
 	screendivisor    float32
 	intscreendivisor int
@@ -40,66 +36,67 @@ var (
 	baseMusicVolume    = 0.6
 )
 
+// initScreenSize picks up the monitor size. Without a display (headless runs)
+// there is no monitor, so a fixed logical size is used instead.
+func initScreenSize() {
+	width, height = 1280, 720
+	if cfg.windowed {
+		width, height = cfg.windowWidth, cfg.windowHeight
+	} else if !headless {
+		if m := ebiten.Monitor(); m != nil {
+			width, height = m.Size()
+		}
+	}
+	screenWidth = float32(width)
+	screenHeight = float32(height)
+}
+
+// set when running the simulation without a window
+var headless bool
+
 func gameinit() {
-	rand.Seed(time.Now().UnixNano())
+	initScreenSize()
+	registerCoreSubscribers()
+
+	screendivisor = 30
+	intscreendivisor = 30
+	game.camera.setZoom(1)
+	loadTileDefs()
 
 	// Load map via shared mapio package for unification with editor
-	if md, err := mapio.LoadMapFromFile("map.txt"); err != nil {
+	if err := loadMap(cfg.mapPath); err != nil {
 		fmt.Println("Failed to load map via mapio, falling back to legacy loader:", err)
-		readMapData() // legacy fallback
-	} else {
-		// Transfer data into game.currentmap
-		game.currentmap.width = md.Width
-		game.currentmap.height = md.Height
-		game.currentmap.data = make([][]int, md.Height)
-		game.currentmap.texture = make([][]*ebiten.Image, md.Height)
-		for y := 0; y < md.Height; y++ {
-			row := make([]int, md.Width)
-			copy(row, md.Tiles[y])
-			game.currentmap.data[y] = row
-			game.currentmap.texture[y] = make([]*ebiten.Image, md.Width)
-		}
-		// Nodes
-		for _, n := range md.Nodes {
-			node := createNode(n.ID, createPos(n.Pos.X, n.Pos.Y))
-			game.currentmap.nodes = append(game.currentmap.nodes, node)
-		}
-		// Paths
-		for _, p := range md.Paths {
-			path := createPath(findNodeByID(p.NodeAID), findNodeByID(p.NodeBID), p.Cost)
-			game.currentmap.paths = append(game.currentmap.paths, path)
-		}
-		// Sprites
-		for _, s := range md.Sprites {
-			createSprite(createPos(s.Pos.X, s.Pos.Y), s.Type)
-		}
-		// NPCs
-		for _, npc := range md.NPCs {
-			createNPCWithSprite(createPos(npc.Pos.X, npc.Pos.Y), npc.Dialogues, npc.SpritePath)
-		}
-		// Initialize runtime spawners from map spawners
-		initSpawners(md)
+		readMapData(cfg.mapPath) // legacy fallback
 	}
-	parseTextureAndSprites()
 
 	// Initialize new animation system
 	animationManager = NewAnimationManager()
-	if err := animationManager.LoadManifest("import/animations.json"); err != nil {
+	if err := animationManager.LoadManifest(assetPath("import/animations.json")); err != nil {
 		fmt.Println("Animation manifest load failed:", err)
 	}
 
-	ebiten.SetFullscreen(true)
-	ebiten.SetWindowTitle("rpg")
+	if !headless {
+		if cfg.windowed {
+			ebiten.SetWindowSize(cfg.windowWidth, cfg.windowHeight)
+		} else {
+			ebiten.SetFullscreen(true)
+		}
+		ebiten.SetWindowTitle("rpg")
+	}
 
 	createCharacter()
+	// start the camera on the player so the first frames don't interpolate from the origin
+	p := game.entities.players[0]
+	p.teleport(p.pos)
+	p.placeAtSpawn("")
 	// Spawn default enemies/NPC only if map didn't provide any
-	if len(game.currentmap.enemies) == 0 {
-		createEnemy(createPos(500, 500))
-		createEnemy(createPos(700, 500))
-		createEnemy(createPos(500, 400))
-		createEnemy(createPos(400, 900))
+	if len(game.entities.enemies) == 0 {
+		createEnemy(createPos(500, 500), -1)
+		createEnemy(createPos(700, 500), -1)
+		createEnemy(createPos(500, 400), -1)
+		createEnemy(createPos(400, 900), -1)
 	}
-	if len(game.currentmap.npcs) == 0 {
+	if len(game.entities.npcs) == 0 {
 		createNPC(createPos(600, 600), []string{
 			"Hey there adventurer!",
 			"Nice day to wander the plains, isn't it?",
@@ -107,14 +104,13 @@ func gameinit() {
 		})
 	}
 
-	screendivisor = 30
-	intscreendivisor = 30
-
-	game.camera.zoom = 1
+	if headless || cfg.mute {
+		return
+	}
 
 	// Initialize audio context & load menu music
 	audioCtx = audio.NewContext(44100)
-	musicPath := "music/rpg main theme.wav"
+	musicPath := assetPath("music/rpg main theme.wav")
 	if info, statErr := os.Stat(musicPath); statErr != nil {
 		log.Println("[AUDIO] music file not found:", musicPath, statErr)
 	} else {
@@ -153,183 +149,104 @@ func gameinit() {
 }
 
 type gamemap struct {
-	// map data (dynamic slices now)
-	// 0 = void, 1 = mountains, 2 = plains, 3 = dry
-	data    [][]int
-	texture [][]*ebiten.Image
+	// file the map was loaded from, portal targets are relative to it
+	path string
 
 	height int
 	width  int
-	// non-hostile talkable NPCs
-	npcs []*npc
+
+	// tiles of every layer, streamed in around the camera (see chunks.go);
+	// read them with safeTile / layerTile
+	chunks chunkCache
 
 	paths []path
 	nodes []node
+	// paths & nodes ready for searching, see navgraph.go
+	nav *navGraph
+	// per player fields chasing enemies follow, see flowfield.go
+	flows map[entityID]*flowField
 
-	players []*character
-	enemies []*enemy
 	// sprites captured from map (trees etc). Kept minimal for now; creation still handled elsewhere.
 	sprites []mapio.Sprite
-}
-
-// read more in gamestate
-type camera struct {
-	pos pos
-
-	//used in rendering and collision checking
-	zoom float32
-}
-
-func offsetsx(tobeoffset float32) float32 {
-	return ((tobeoffset-game.camera.pos.float_x)*game.camera.zoom + screenWidth/2)
-}
-func offsetsy(tobeoffset float32) float32 {
-	return ((tobeoffset-game.camera.pos.float_y)*game.camera.zoom + screenHeight/2)
 
+	// exits to other maps & arrival points, see maps.go
+	portals     []mapio.Portal
+	spawnPoints []mapio.SpawnPoint
 }
 
 var game Game
 
 type Game struct {
-	// 0 menu / 1 menu and options / 3 in game
-	stateid   int
-	prevState int
+	// menu / world / pause... screens, see scene.go
+	scenes sceneStack
 
 	// maps are stored in arrays (see in type map)
 	//
 	// this is the current map that is  being used//while rendered map array size is constant to 144 (16*9) currentmapid is not
 	currentmap gamemap
 
-	// counts the time since start of game
+	// length of the current simulation step in seconds
 	//
-	// get updated every frame
+	// set by simulation.Step, read by the gameplay code
 	deltatime float64
 
 	// date of last update
 	lastUpdateTime time.Time
 
+	// render side timing: time between Draw calls and the interpolation
+	// factor between the previous and current simulation step
+	lastDrawTime time.Time
+	frameDelta   float64
+	alpha        float32
+
 	// contains the camera positions
 	//
 	// this is used in the rendering, it offsets the drawing positions
 	camera camera
+
+	// gameplay state & update order, independent of rendering
+	sim simulation
+
+	// players, enemies, NPCs and trees of the current map, see entity.go
+	entities entityRegistry
+	// gameplay events, see events.go
+	events eventBus
+	// fade between maps when a portal is used, see maps.go
+	transition mapTransition
+
+	// seeded random streams, see rng.go
+	rng *rngService
+
+	// input recording / playback, nil when unused (see replay.go)
+	recorder *replayRecorder
+	replay   *replayPlayer
 }
 
 // Update method of the Game
 func (g *Game) Update() error {
-	go checkZoom()
+	// Real time since the previous update, consumed in fixed simulation steps
+	now := time.Now()
+	var dt float64
+	if !game.lastUpdateTime.IsZero() {
+		dt = now.Sub(game.lastUpdateTime).Seconds()
+	}
+	game.lastUpdateTime = now
 
 	// Update cursor position
 	cx, cy := ebiten.CursorPosition()
 	curspos.float_x = float32(cx)
 	curspos.float_y = float32(cy)
 
-	// Update buttons (hover/click detection) before using their pressed flags
-	playbtn.UpdateButton()
-	optionsbtn.UpdateButton()
-	options_exitbtn.UpdateButton()
-	exitbtn.UpdateButton()
-	resumeBtn.UpdateButton()
-	pauseMenuBtn.UpdateButton()
-	pauseExitBtn.UpdateButton()
-
-	if optionsbtn.pressed {
-		game.stateid = 1
-	}
-	if options_exitbtn.pressed {
-		game.stateid = 0
-	}
-	if playbtn.pressed {
-		game.stateid = 3
-	}
-	if exitbtn.pressed {
-		fmt.Println("exited with code 0")
-		os.Exit(0)
-	}
-	// Pause overlay buttons (state 2)
-	if resumeBtn.pressed && game.stateid == 2 {
-		game.stateid = 3
-	}
-	if pauseMenuBtn.pressed && game.stateid == 2 {
-		game.stateid = 0
-	}
-	if pauseExitBtn.pressed && game.stateid == 2 {
-		fmt.Println("exited with code 0")
-		os.Exit(0)
-	}
-
-	// ESC behavior with pause state
-	if inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
-		switch game.stateid {
-		case 3: // in game -> pause
-			game.stateid = 2
-		case 2: // pause -> back to game
-			game.stateid = 3
-		case 0, 1: // menus -> exit
-			fmt.Println("exited with code 0")
-			os.Exit(0)
-		}
-	}
-	// Also allow 'P' to toggle pause in-game
-	if inpututil.IsKeyJustPressed(ebiten.KeyP) {
-		if game.stateid == 3 {
-			game.stateid = 2
-		} else if game.stateid == 2 {
-			game.stateid = 3
-		}
-	}
-
-	// Reset one-shot pressed state so it doesn't trigger repeatedly
-	playbtn.pressed = false
-	optionsbtn.pressed = false
-	options_exitbtn.pressed = false
-	exitbtn.pressed = false
-	resumeBtn.pressed = false
-	pauseMenuBtn.pressed = false
-	pauseExitBtn.pressed = false
-
-	// (Animation cycle handled per AnimationPlayer now)
-
-	// Damage indicators
-	updateDamageIndicators(game.deltatime)
-
-	// Menu particle/visual effects
-	if game.stateid == 0 || game.stateid == 1 {
-		updateMenuEffects(game.deltatime)
-	}
-
-	// NPC interaction handling (only ingame, not paused)
-	if game.stateid == 3 {
-		updateNPCInteractions()
-		updateNPCAnimations(game.deltatime)
-		// Runtime spawning system
-		updateSpawners(game.deltatime)
+	if err := game.scenes.Update(dt); err != nil {
+		return err
 	}
 
 	// Music volume management (keeps music always playing; lowers on pause)
-	updateMusicVolume(game.stateid, game.deltatime)
-	game.prevState = game.stateid
-
-	// Always keep music playing (started in init). Volume handled above.
+	updateMusicVolume(game.scenes.musicScale(), dt)
 
 	return nil
 }
 
-var (
-	// Case 0
-	playbtn    = createButton("Play", 150, 50, uitransparent, uilightgray, uigray, onearg_createPos(25))
-	optionsbtn = createButton("Options", 150, 50, uitransparent, uilightgray, uigray, createPos(25, 85))
-	exitbtn    = createButton("Exit", 150, 50, uitransparent, uilightgray, uigray, createPos(25, 145))
-
-	// Case 1
-	options_exitbtn = createButton("Back to menu", 150, 50, uitransparent, uilightgray, uigray, onearg_createPos(25))
-	testslider      = createSlider("testslider", 500, 20, 5, 10, uigray, uilightgray, uigray, createPos(230, 80))
-
-	// Pause (state 2)
-	resumeBtn    = createButton("Resume", 150, 45, uitransparent, uilightgray, uigray, createPos(50, 60))
-	pauseMenuBtn = createButton("Menu", 150, 45, uitransparent, uilightgray, uigray, createPos(50, 115))
-	pauseExitBtn = createButton("Exit", 150, 45, uitransparent, uilightgray, uigray, createPos(50, 170))
-)
-
 var screenGlobal *ebiten.Image
 
 // Draw method of the Game
@@ -338,95 +255,16 @@ func (g *Game) Draw(screen *ebiten.Image) {
 	screenGlobal = screen
 
 	now := time.Now()
-	game.deltatime = now.Sub(game.lastUpdateTime).Seconds()
-	game.lastUpdateTime = now
-
-	// ESC handling moved to Update for state-aware behavior
-
-	switch game.stateid {
-	case 0:
-		drawFancyMenu(screen, 0)
-
-	case 1:
-		drawFancyMenu(screen, 1)
-
-	case 3:
-		sortDrawables()
-		for i := 0; i < game.currentmap.height && i < len(game.currentmap.texture); i++ {
-			for j := 0; j < game.currentmap.width && j < len(game.currentmap.texture[i]); j++ {
-				if tex := game.currentmap.texture[i][j]; tex != nil {
-					drawTile(screen, tex, i, j)
-				}
-			}
-		}
-
-		for i := 0; i < len(drawables); i++ {
-			drawables[i].giveId(i)
-			drawables[i].draw(screen)
-		}
-
-		// for i := 0; i < len(game.currentmap.paths); i++ {
-		// 	drawPath(screen, game.currentmap.paths[i])
-		// }
-
-		// for i := 0; i < len(game.currentmap.nodes); i++ {
-		// 	n := game.currentmap.nodes[i]
-		// 	ebitenutil.DebugPrintAt(screen, strconv.Itoa(n.id), int(offsetsx(n.pos.float_x)), int(offsetsy(n.pos.float_y)))
-		// }
-
-		p := 0
-		game.currentmap.players[p].drawUi()
-
-		// Draw floating damage after entities so it's on top
-		drawDamageIndicators()
-		// Draw conversation if active
-		drawConversationUI(screen)
-
-	case 2: // paused overlay: draw game scene behind then overlay
-		// First draw game world with dynamic bounds
-		sortDrawables()
-		for i := 0; i < game.currentmap.height && i < len(game.currentmap.texture); i++ {
-			for j := 0; j < game.currentmap.width && j < len(game.currentmap.texture[i]); j++ {
-				if tex := game.currentmap.texture[i][j]; tex != nil {
-					drawTile(screen, tex, i, j)
-				}
-			}
-		}
-		for i := 0; i < len(drawables); i++ {
-			drawables[i].giveId(i)
-			drawables[i].draw(screen)
-		}
-		p := 0
-		game.currentmap.players[p].drawUi()
-		// damage + conversations on top
-		drawDamageIndicators()
-		drawConversationUI(screen)
-		// Washed overlay (desaturated feel via tinted semi-transparent layer)
-		vector.DrawFilledRect(screen, 0, 0, screenWidth, screenHeight, color.RGBA{40, 40, 40, 170}, false)
-		// Pause panel
-		panelW := float32(260)
-		panelH := float32(200)
-		panelX := (screenWidth - panelW) / 2
-		panelY := (screenHeight - panelH) / 2
-		vector.DrawFilledRect(screen, panelX+4, panelY+4, panelW, panelH, color.RGBA{0, 0, 0, 120}, false) // shadow
-		vector.DrawFilledRect(screen, panelX, panelY, panelW, panelH, color.RGBA{70, 80, 75, 230}, false)
-		// Title
-		fmtStr := "PAUSED"
-		fbx := int(panelX + (panelW-float32(len(fmtStr))*7)/2)
-		fby := int(panelY + 10)
-		ebitenutil.DebugPrintAt(screen, fmtStr, fbx, fby)
-		// Reposition pause buttons relative to panel for neat layout
-		resumeBtn.pos.float_x = panelX + panelW/2 - resumeBtn.width/2
-		resumeBtn.pos.float_y = panelY + 50
-		pauseMenuBtn.pos.float_x = panelX + panelW/2 - pauseMenuBtn.width/2
-		pauseMenuBtn.pos.float_y = panelY + 100
-		pauseExitBtn.pos.float_x = panelX + panelW/2 - pauseExitBtn.width/2
-		pauseExitBtn.pos.float_y = panelY + 150
-		resumeBtn.DrawButton(screen)
-		pauseMenuBtn.DrawButton(screen)
-		pauseExitBtn.DrawButton(screen)
-
+	if !game.lastDrawTime.IsZero() {
+		game.frameDelta = now.Sub(game.lastDrawTime).Seconds()
 	}
+	game.lastDrawTime = now
+	game.alpha = game.sim.alpha()
+	game.camera.renderPos = lerpPos(game.camera.prevPos, game.camera.pos, game.alpha)
+	game.camera.renderPos.float_x += game.camera.shakeOffset.float_x
+	game.camera.renderPos.float_y += game.camera.shakeOffset.float_y
+
+	game.scenes.Draw(screen)
 
 	fps := ebiten.CurrentFPS()
 	fpsText := fmt.Sprintf("FPS: %.2f", fps)
@@ -438,44 +276,9 @@ func (g *Game) Layout(outsideWidth, outsideHeight int) (screenWidth, screenHeigh
 	return outsideWidth, outsideHeight
 }
 
-// managePauseAudio handles quick fade out/in when pausing/unpausing.
-func managePauseAudio(prev, cur int, dt float64) {
-	if menuMusicPlayer == nil {
-		return
-	}
-	// Only affect if we were/are in menu music playing states (0,1) OR paused state
-	if prev == cur {
-		return
-	}
-	// If entering pause from menu (0 or 1) we fast fade
-	if (cur == 2) && (prev == 0 || prev == 1) {
-		// reduce volume rapidly
-		v := menuMusicPlayer.Volume()
-		v -= float64(dt) * 2.5
-		if v < 0 {
-			v = 0
-		}
-		menuMusicPlayer.SetVolume(v)
-		if v == 0 {
-			menuMusicPlayer.Pause()
-		}
-	}
-	// If leaving pause back to menu, restore volume quickly and play
-	if (prev == 2) && (cur == 0 || cur == 1) {
-		if !menuMusicPlayer.IsPlaying() {
-			menuMusicPlayer.Play()
-		}
-		v := menuMusicPlayer.Volume()
-		v += float64(dt) * 3
-		if v > 0.6 {
-			v = 0.6
-		}
-		menuMusicPlayer.SetVolume(v)
-	}
-}
-
-// updateMusicVolume keeps music playing; when paused (state 2) it squashes volume lower and restores otherwise.
-func updateMusicVolume(state int, dt float64) {
+// updateMusicVolume keeps music playing; scenes like pause squash the volume
+// (scale < 1) and it is restored once they're gone.
+func updateMusicVolume(scale float64, dt float64) {
 	if menuMusicPlayer == nil {
 		return
 	}
@@ -483,10 +286,7 @@ func updateMusicVolume(state int, dt float64) {
 	if !menuMusicPlayer.IsPlaying() {
 		menuMusicPlayer.Play()
 	}
-	target := baseMusicVolume
-	if state == 2 { // paused -> squash
-		target = baseMusicVolume * 0.25
-	}
+	target := baseMusicVolume * scale
 	cur := menuMusicPlayer.Volume()
 	// smooth approach
 	speed := 2.5 // volume units per second
@@ -508,8 +308,80 @@ func updateMusicVolume(state int, dt float64) {
 	menuMusicPlayer.SetVolume(cur)
 }
 
+// exitGame flushes an active input recording and quits.
+func exitGame() {
+	if game.recorder != nil {
+		if err := game.recorder.save(); err != nil {
+			log.Println("replay save failed:", err)
+		}
+		game.recorder = nil
+	}
+	fmt.Println("exited with code 0")
+	os.Exit(0)
+}
+
 func main() {
+	if err := parseLaunchConfig(); err != nil {
+		log.Fatal(err)
+	}
+
+	var rf *replayFile
+	if cfg.replayPath != "" {
+		var err error
+		if rf, err = loadReplay(cfg.replayPath); err != nil {
+			log.Fatal(err)
+		}
+		// a replay only reproduces with the seed & map it was recorded with
+		cfg.seed = rf.Seed
+		if rf.Map != "" {
+			cfg.mapPath = rf.Map
+		}
+	}
+
+	game.rng = newRNG(cfg.seed)
+	// printed so bug reports can be reproduced with -seed
+	fmt.Println("seed:", cfg.seed)
+
+	headless = cfg.headlessTicks > 0 || cfg.check
 	gameinit()
+	// whatever the map & manifests referenced but couldn't be loaded
+	assets.report()
+
+	if rf != nil {
+		game.entities.players[0].teleport(createPos(rf.StartX, rf.StartY))
+		game.replay = newReplayPlayer(rf)
+	}
+	if cfg.recordPath != "" {
+		game.recorder = newReplayRecorder(cfg.recordPath, cfg.seed, cfg.mapPath, game.entities.players[0].pos)
+	}
+
+	if headless {
+		ticks := cfg.headlessTicks
+		if game.replay != nil {
+			ticks = rf.Ticks
+		}
+		runHeadless(ticks)
+		if cfg.check {
+			if err := game.replay.check(); err != nil {
+				fmt.Println("replay check FAILED:", err)
+				os.Exit(1)
+			}
+			fmt.Println("replay check passed")
+		}
+		return
+	}
+	if game.replay != nil || cfg.startInGame {
+		game.scenes.reset(&worldScene{})
+	} else {
+		game.scenes.reset(&menuScene{})
+	}
+	defer func() {
+		if game.recorder != nil {
+			if err := game.recorder.save(); err != nil {
+				log.Println("replay save failed:", err)
+			}
+		}
+	}()
 	if err := ebiten.RunGame(&Game{}); err != nil {
 		log.Fatal(err)
 	}
@@ -3,12 +3,14 @@ package main
 import (
 	"fmt"
 	"image/color"
-	"image/png"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
 
+	"rpg/assetio"
+	"rpg/mapio"
+
 	"github.com/hajimehoshi/ebiten/v2"
 )
 
@@ -22,69 +24,85 @@ var (
 	lightGreen = color.RGBA{144, 238, 144, 255}
 	lightBrown = color.RGBA{222, 184, 135, 255}
 	voidColor  = color.RGBA{64, 64, 64, 255}
+
+	// collision layer tints
+	blockedColor  = color.RGBA{220, 60, 60, 255}
+	walkableColor = color.RGBA{60, 200, 90, 255}
 )
 
 type AssetManager struct {
-	tileTextures   [4]*ebiten.Image // For tile types 0, 1, 2, 3
-	fallbackColors [4]color.RGBA
+	tiles          *mapio.TileSet        // tile definitions shared with the game (import/tiles.json)
+	tileTextures   map[int]*ebiten.Image // preview texture per tile id
 	assetsLoaded   bool
 	npcSpritePaths []string // scanned NPC sprite paths (relative like import/Characters/...)
+
+	// shared with the game: decoding, caching & missing asset tracking
+	loader      *assetio.Loader
+	images      map[string]*ebiten.Image
+	placeholder *ebiten.Image
+	void        *ebiten.Image
 }
 
 func NewAssetManager() AssetManager {
 	return AssetManager{
-		fallbackColors: [4]color.RGBA{
-			voidColor,  // 0 = void
-			darkGray,   // 1 = mountains
-			lightGreen, // 2 = plains/grass
-			lightBrown, // 3 = dry
-		},
+		tiles:        emptyTileSet(),
+		tileTextures: make(map[int]*ebiten.Image),
 		assetsLoaded: false,
+		loader:       assetio.NewLoader(".."),
+		images:       make(map[string]*ebiten.Image),
 	}
 }
 
+func emptyTileSet() *mapio.TileSet {
+	ts, _ := mapio.ParseTileSet([]byte(`{"tiles":[]}`))
+	return ts
+}
+
 func (a *AssetManager) LoadAssets(importPath string) error {
 	fmt.Printf("Attempting to load assets from: %s\n", importPath)
-
-	// Try to load actual textures
-	grassPath := filepath.Join(importPath, "tiles", "Grass_S1.png")
-	dryPath := filepath.Join(importPath, "tiles", "Dryland_S1.png")
-
-	// Load grass texture for plains (type 2)
-	if img, err := a.loadPNG(grassPath); err == nil {
-		a.tileTextures[2] = a.resizeImage(img, tileSize, tileSize)
-		fmt.Println("Loaded grass texture")
-	}
-
-	// Load dry texture for dry land (type 3)
-	if img, err := a.loadPNG(dryPath); err == nil {
-		a.tileTextures[3] = a.resizeImage(img, tileSize, tileSize)
-		fmt.Println("Loaded dry texture")
+	// asset paths are relative to the directory containing import/, like in the game
+	a.loader = assetio.NewLoader(filepath.Dir(importPath))
+	a.images = make(map[string]*ebiten.Image)
+
+	// Tile definitions decide the palette & textures
+	if ts, err := mapio.LoadTileSet(filepath.Join(importPath, "tiles.json")); err != nil {
+		fmt.Println("Could not load tile definitions:", err)
+	} else {
+		a.tiles = ts
 	}
-
-	// Create fallback textures for missing assets
-	a.createFallbackTextures()
+	a.loadTileTextures()
 
 	// Scan NPC sprites
 	a.scanNPCSprites(importPath)
 
 	a.assetsLoaded = true
+	a.loader.Report(os.Stdout)
 	return nil
 }
 
+// loadPNG loads an image relative to the asset root. On error the "missing
+// texture" placeholder is returned along with the error.
 func (a *AssetManager) loadPNG(path string) (*ebiten.Image, error) {
-	file, err := os.Open(path)
-	if err != nil {
-		return nil, err
+	if img, ok := a.images[path]; ok {
+		return img, nil
 	}
-	defer file.Close()
-
-	imgData, err := png.Decode(file)
+	decoded, err := a.loader.Image(path)
 	if err != nil {
-		return nil, err
+		if a.placeholder == nil {
+			a.placeholder = ebiten.NewImageFromImage(decoded)
+		}
+		return a.placeholder, err
 	}
+	img := ebiten.NewImageFromImage(decoded)
+	a.images[path] = img
+	return img, nil
+}
 
-	return ebiten.NewImageFromImage(imgData), nil
+// GetImage lazily loads an image (e.g. an NPC sprite) by its game-relative
+// path, falling back on the placeholder.
+func (a *AssetManager) GetImage(path string) *ebiten.Image {
+	img, _ := a.loadPNG(path)
+	return img
 }
 
 func (a *AssetManager) resizeImage(img *ebiten.Image, width, height int) *ebiten.Image {
@@ -100,24 +118,48 @@ func (a *AssetManager) resizeImage(img *ebiten.Image, width, height int) *ebiten
 	return resized
 }
 
-func (a *AssetManager) createFallbackTextures() {
-	for i := 0; i < 4; i++ {
-		if a.tileTextures[i] == nil {
-			// Create a simple colored square
-			img := ebiten.NewImage(tileSize, tileSize)
-			img.Fill(a.fallbackColors[i])
-			a.tileTextures[i] = img
+// loadTileTextures builds a preview texture for every tile definition: the
+// heaviest variant, the fully surrounded autotile, or a square of its color.
+func (a *AssetManager) loadTileTextures() {
+	a.tileTextures = make(map[int]*ebiten.Image)
+	a.void = ebiten.NewImage(tileSize, tileSize)
+	a.void.Fill(voidColor)
+
+	for _, def := range a.tiles.Tiles {
+		path := ""
+		if def.Autotile != nil {
+			path = def.Autotile.Texture(def.Autotile.Mask(true, true, true, true))
+		} else {
+			best := 0
+			for _, v := range def.Variants {
+				if v.Weight > best || path == "" {
+					best, path = v.Weight, v.Texture
+				}
+			}
+		}
+		if path != "" {
+			if img, err := a.loadPNG(path); err == nil {
+				a.tileTextures[def.ID] = a.resizeImage(img, tileSize, tileSize)
+				continue
+			}
 		}
+		img := ebiten.NewImage(tileSize, tileSize)
+		img.Fill(def.Color())
+		a.tileTextures[def.ID] = img
 	}
 }
 
+// GetTileTexture returns the preview texture of a tile id (void for unknown ids).
 func (a *AssetManager) GetTileTexture(tileType int) *ebiten.Image {
-	if tileType < 0 || tileType >= len(a.tileTextures) {
-		return a.tileTextures[0] // Return void texture for invalid types
+	if img, ok := a.tileTextures[tileType]; ok {
+		return img
 	}
-	return a.tileTextures[tileType]
+	return a.void
 }
 
+// Tiles returns the loaded tile definitions.
+func (a *AssetManager) Tiles() *mapio.TileSet { return a.tiles }
+
 // scanNPCSprites walks the Characters directory under importPath and records all PNG files.
 func (a *AssetManager) scanNPCSprites(importPath string) {
 	base := filepath.Join(importPath, "Characters")
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"rpg/mapio"
+)
+
+// chunkPager pages the chunks of a chunked map (a .chunks directory, see
+// mapio.ChunkStore) into the editor's map as the camera gets near them, so
+// opening a big map only reads what's on screen. Saving writes back just
+// the chunks that changed.
+//
+// The map keeps its full size; chunks that aren't paged in yet read as void
+// and the tools leave them alone. Only reading is paged, not memory: the
+// tiles live in the map's full size grids (one per layer a chunk uses) and
+// paged in chunks are never dropped, so the editor still holds the whole
+// map once it has been scrolled over. Unlike the game, it doesn't stay flat.
+type chunkPager struct {
+	store *mapio.ChunkStore
+	// chunks as they are on disk, saving compares against them
+	loaded map[[2]int]*mapio.Chunk
+}
+
+// openChunkPager opens a chunked map. The returned map has the objects and
+// size of the map but no tiles until update pages them in (the ground grid
+// is allocated full size right away though, see chunkPager).
+func openChunkPager(dir string) (*chunkPager, *mapio.MapData, error) {
+	store, err := mapio.OpenChunkStore(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	m := *store.Header
+	m.Tiles = nil
+	m.Layers = []mapio.TileLayer{}
+	m.Resize(m.Width, m.Height, 0)
+	return &chunkPager{store: store, loaded: make(map[[2]int]*mapio.Chunk)}, &m, nil
+}
+
+// update pages in the chunks on screen and one more around them.
+func (p *chunkPager) update(m *mapio.MapData, c *Camera) error {
+	size := p.store.Size
+	x0 := int((c.X-float64(windowWidth)/(2*c.Zoom))/tileSize) - 1
+	y0 := int((c.Y-float64(windowHeight)/(2*c.Zoom))/tileSize) - 1
+	x1 := int((c.X+float64(windowWidth)/(2*c.Zoom))/tileSize) + 1
+	y1 := int((c.Y+float64(windowHeight)/(2*c.Zoom))/tileSize) + 1
+	cx0, cy0 := mapio.ChunkOf(x0, y0, size)
+	cx1, cy1 := mapio.ChunkOf(x1, y1, size)
+	cw, ch := m.ChunkCount(size)
+
+	for cy := max(cy0-1, 0); cy <= min(cy1+1, ch-1); cy++ {
+		for cx := max(cx0-1, 0); cx <= min(cx1+1, cw-1); cx++ {
+			if _, ok := p.loaded[[2]int{cx, cy}]; ok {
+				continue
+			}
+			chunk, err := p.store.LoadChunk(cx, cy)
+			if err != nil {
+				return err
+			}
+			m.ApplyChunk(chunk)
+			p.loaded[[2]int{cx, cy}] = chunk
+		}
+	}
+	return nil
+}
+
+// loadAll pages in every chunk, for commands that need the whole map.
+func (p *chunkPager) loadAll(m *mapio.MapData) error {
+	cw, ch := m.ChunkCount(p.store.Size)
+	for cy := 0; cy < ch; cy++ {
+		for cx := 0; cx < cw; cx++ {
+			if _, ok := p.loaded[[2]int{cx, cy}]; ok {
+				continue
+			}
+			chunk, err := p.store.LoadChunk(cx, cy)
+			if err != nil {
+				return err
+			}
+			m.ApplyChunk(chunk)
+			p.loaded[[2]int{cx, cy}] = chunk
+		}
+	}
+	return nil
+}
+
+// editable reports whether the chunk of a tile is paged in.
+func (p *chunkPager) editable(x, y int) bool {
+	cx, cy := mapio.ChunkOf(x, y, p.store.Size)
+	_, ok := p.loaded[[2]int{cx, cy}]
+	return ok
+}
+
+// save writes the header and the chunks edited since they were paged in,
+// returning how many chunks were written.
+func (p *chunkPager) save(m *mapio.MapData) (int, error) {
+	if err := p.store.SaveHeader(m); err != nil {
+		return 0, err
+	}
+	written := 0
+	for k, orig := range p.loaded {
+		cur := m.ExtractChunk(k[0], k[1], p.store.Size)
+		if cur.Equal(orig) {
+			continue
+		}
+		if err := p.store.SaveChunk(cur); err != nil {
+			return written, err
+		}
+		p.loaded[k] = cur
+		written++
+	}
+	return written, nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+
+	"rpg/mapio"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+var (
+	diagErrorColor   = color.RGBA{220, 40, 40, 255}
+	diagWarningColor = color.RGBA{240, 170, 30, 255}
+)
+
+// checkMap validates the map and returns a status suffix like " (1 error, 2 warnings)".
+func (e *MapEditor) checkMap() string {
+	e.diagnostics = mapio.ValidateWithTiles(e.mapData, e.assets.Tiles())
+	for _, d := range e.diagnostics {
+		fmt.Println(d)
+	}
+	errs, warns := e.diagnostics.Count()
+	if errs == 0 && warns == 0 {
+		return " (no problems)"
+	}
+	return fmt.Sprintf(" (%d errors, %d warnings, Ctrl+K to list)", errs, warns)
+}
+
+// drawDiagnostics marks the problems on the map and lists them bottom right.
+func (e *MapEditor) drawDiagnostics(screen *ebiten.Image) {
+	if !e.showDiagnostics {
+		return
+	}
+	for _, d := range e.diagnostics {
+		if !d.HasPos {
+			continue
+		}
+		col := diagWarningColor
+		if d.Severity == mapio.SeverityError {
+			col = diagErrorColor
+		}
+		sx, sy := e.offsetsx(d.Pos.X), e.offsetsy(d.Pos.Y)
+		vector.StrokeCircle(screen, sx, sy, 12, 2, col, false)
+	}
+
+	const maxLines = 12
+	lines := len(e.diagnostics)
+	if lines > maxLines {
+		lines = maxLines
+	}
+	panelW, panelH := 520, 30+lines*15
+	panelX, panelY := windowWidth-panelW-10, windowHeight-panelH-10
+	vector.DrawFilledRect(screen, float32(panelX), float32(panelY), float32(panelW), float32(panelH), color.RGBA{40, 40, 40, 220}, false)
+	vector.StrokeRect(screen, float32(panelX), float32(panelY), float32(panelW), float32(panelH), 2, color.RGBA{0, 0, 0, 255}, false)
+
+	errs, warns := e.diagnostics.Count()
+	ebitenutil.DebugPrintAt(screen, fmt.Sprintf("Map check: %d errors, %d warnings (Ctrl+K to hide)", errs, warns), panelX+8, panelY+6)
+	for i := 0; i < lines; i++ {
+		d := e.diagnostics[i]
+		text := d.Message
+		if d.HasPos {
+			text = fmt.Sprintf("(%.0f, %.0f) %s", d.Pos.X, d.Pos.Y, d.Message)
+		}
+		if d.Severity == mapio.SeverityError {
+			text = "E " + text
+		} else {
+			text = "W " + text
+		}
+		if len(text) > 80 {
+			text = text[:77] + "..."
+		}
+		ebitenutil.DebugPrintAt(screen, text, panelX+8, panelY+24+i*15)
+	}
+	if len(e.diagnostics) > maxLines {
+		ebitenutil.DebugPrintAt(screen, fmt.Sprintf("+%d more in the console", len(e.diagnostics)-maxLines), panelX+panelW-150, panelY+6)
+	}
+}
@@ -6,8 +6,11 @@ toolchain go1.23.1
 
 replace rpg/mapio => ../mapio
 
+replace rpg/assetio => ../assetio
+
 require (
 	github.com/hajimehoshi/ebiten/v2 v2.8.0
+	rpg/assetio v0.0.0-00010101000000-000000000000
 	rpg/mapio v0.0.0-00010101000000-000000000000
 )
 
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"image/color"
 	"log"
+	"os"
 	"rpg/mapio"
 	"strings"
 	"unicode/utf8"
@@ -25,9 +26,16 @@ const (
 type MapEditor struct {
 	camera  Camera
 	mapData *mapio.MapData
-	ui      UI
-	tools   ToolSystem
-	assets  AssetManager
+	// file (or .chunks directory) the map is loaded from & saved to
+	mapPath string
+	// set while editing a chunked map, see chunks.go
+	pager  *chunkPager
+	ui     UI
+	tools  ToolSystem
+	assets AssetManager
+	// last mapio.Validate result, listed with Ctrl+K
+	diagnostics     mapio.Diagnostics
+	showDiagnostics bool
 	// dialogue editing state
 	editingDialogue bool
 	editDialogueIdx int
@@ -37,6 +45,12 @@ type MapEditor struct {
 func (e *MapEditor) Update() error {
 	e.camera.Update()
 	e.ui.Update()
+	if e.pager != nil {
+		if err := e.pager.update(e.mapData, &e.camera); err != nil {
+			fmt.Printf("Error loading chunk: %v\n", err)
+			e.ui.ShowStatus("Error loading chunk!")
+		}
+	}
 
 	// NPC editing & inline dialogue editing
 	if e.ui.selectedTool == ToolNPC {
@@ -145,19 +159,35 @@ func (e *MapEditor) Update() error {
 	// Handle file operations (save now requires Ctrl+Shift+S)
 	if ebiten.IsKeyPressed(ebiten.KeyControl) {
 		if ebiten.IsKeyPressed(ebiten.KeyShift) && inpututil.IsKeyJustPressed(ebiten.KeyS) {
-			mapio.SaveMapToFile(e.mapData, "../map.txt")
-			fmt.Println("Map saved!")
-			e.ui.ShowStatus("Map saved!")
+			if err := e.saveMap(); err != nil {
+				fmt.Printf("Error saving map: %v\n", err)
+				e.ui.ShowStatus("Error saving map!")
+			} else {
+				fmt.Println("Map saved!")
+				e.ui.ShowStatus("Map saved!" + e.checkMap())
+			}
 		}
 		if inpututil.IsKeyJustPressed(ebiten.KeyO) {
-			var err error
-			e.mapData, err = mapio.LoadMapFromFile("../map.txt")
-			if err != nil {
+			if err := e.loadMap(); err != nil {
 				fmt.Printf("Error loading map: %v\n", err)
 				e.ui.ShowStatus("Error loading map!")
 			} else {
 				fmt.Println("Map loaded!")
-				e.ui.ShowStatus("Map loaded!")
+				e.ui.ShowStatus("Map loaded!" + e.checkMap())
+			}
+		}
+		// Check the map & toggle the problem list with Ctrl+K
+		if inpututil.IsKeyJustPressed(ebiten.KeyK) {
+			e.showDiagnostics = !e.showDiagnostics
+			if e.showDiagnostics {
+				e.ui.ShowStatus("Checked map" + e.checkMap())
+			}
+		}
+		// Replace the nodes & paths with generated ones with Ctrl+Shift+N
+		if ebiten.IsKeyPressed(ebiten.KeyShift) && inpututil.IsKeyJustPressed(ebiten.KeyN) {
+			if err := e.generateNavGraph(); err != nil {
+				fmt.Printf("Error generating nav graph: %v\n", err)
+				e.ui.ShowStatus("Error generating nav graph!")
 			}
 		}
 		// Undo with Ctrl+Z
@@ -252,6 +282,7 @@ func (e *MapEditor) offsetsy(tobeoffset float32) float32 {
 func (e *MapEditor) updateTools() {
 	// Sync tool system with UI selection
 	e.tools.SetTool(e.ui.GetSelectedTool())
+	e.tools.SetLayer(e.ui.GetSelectedLayer())
 
 	mouseX, mouseY := ebiten.CursorPosition()
 
@@ -347,6 +378,21 @@ func (e *MapEditor) Draw(screen *ebiten.Image) {
 		ebitenutil.DebugPrintAt(screen, lbl, int(sx)-10, int(sy)-22)
 	}
 
+	// Portals & spawn points (authored in Tiled or the JSON map for now)
+	portalColor := color.RGBA{80, 140, 255, 255}
+	for _, p := range e.mapData.Portals {
+		sx, sy := e.offsetsx(p.Pos.X), e.offsetsy(p.Pos.Y)
+		w, h := p.Width*float32(e.camera.Zoom), p.Height*float32(e.camera.Zoom)
+		vector.DrawFilledRect(screen, sx, sy, w, h, color.RGBA{80, 140, 255, 60}, false)
+		vector.StrokeRect(screen, sx, sy, w, h, 2, portalColor, false)
+		ebitenutil.DebugPrintAt(screen, "-> "+p.TargetMap+" "+p.TargetSpawn, int(sx), int(sy)-16)
+	}
+	for _, sp := range e.mapData.SpawnPoints {
+		sx, sy := e.offsetsx(sp.Pos.X), e.offsetsy(sp.Pos.Y)
+		vector.DrawFilledCircle(screen, sx, sy, 5, portalColor, false)
+		ebitenutil.DebugPrintAt(screen, sp.Name, int(sx)+7, int(sy)-8)
+	}
+
 	// Spawner parameter panel
 	if e.ui.selectedTool == ToolSpawner {
 		idx := e.tools.GetSelectedSpawner()
@@ -373,6 +419,7 @@ func (e *MapEditor) Draw(screen *ebiten.Image) {
 
 	// Draw UI elements
 	e.ui.Draw(screen)
+	e.drawDiagnostics(screen)
 
 	// NPC overlay with inline editing
 	if e.ui.selectedTool == ToolNPC {
@@ -463,26 +510,41 @@ func (e *MapEditor) drawMap(screen *ebiten.Image) {
 		endY = e.mapData.Height
 	}
 
-	// Draw tiles using main game's coordinate system
+	// Draw tiles using main game's coordinate system, layer by layer
+	selectedLayer := e.ui.GetSelectedLayer()
 	for y := startY; y < endY; y++ {
 		for x := startX; x < endX; x++ {
 			tileType := e.mapData.GetTile(x, y)
-			texture := e.assets.GetTileTexture(tileType)
-
-			if texture != nil {
-				op := &ebiten.DrawImageOptions{}
-
-				// Apply zoom scaling
-				op.GeoM.Scale(e.camera.Zoom, e.camera.Zoom)
-
-				// Center tiles like in the main game (tile spans center +/- tileSize/2)
-				worldX := float64(x*tileSize - tileSize/2)
-				worldY := float64(y*tileSize - tileSize/2)
-				screenX := (worldX-e.camera.X)*e.camera.Zoom + float64(windowWidth)/2
-				screenY := (worldY-e.camera.Y)*e.camera.Zoom + float64(windowHeight)/2
+			e.drawTileTexture(screen, e.assets.GetTileTexture(tileType), x, y, 1)
+
+			for _, layer := range []string{mapio.LayerDetail, mapio.LayerOverlay} {
+				if v := e.mapData.GetLayerTile(layer, x, y); v != 0 {
+					tex := e.assets.GetTileTexture(v)
+					// the overlay is faded unless it's the one being edited, so what's under it stays visible
+					alpha := float32(1)
+					if layer == mapio.LayerOverlay && selectedLayer != mapio.LayerOverlay {
+						alpha = 0.5
+					}
+					e.drawTileTexture(screen, tex, x, y, alpha)
+				}
+			}
 
-				op.GeoM.Translate(screenX, screenY)
-				screen.DrawImage(texture, op)
+			// collision is only shown while editing it
+			if selectedLayer == mapio.LayerCollision {
+				var tint color.RGBA
+				switch e.mapData.GetLayerTile(mapio.LayerCollision, x, y) {
+				case mapio.CollisionBlocked:
+					tint = blockedColor
+				case mapio.CollisionWalkable:
+					tint = walkableColor
+				default:
+					continue
+				}
+				tint.A = 110
+				sx := e.offsetsx(float32(x*tileSize - tileSize/2))
+				sy := e.offsetsy(float32(y*tileSize - tileSize/2))
+				size := float32(float64(tileSize) * e.camera.Zoom)
+				vector.DrawFilledRect(screen, sx, sy, size, size, tint, false)
 			}
 		}
 	}
@@ -501,6 +563,28 @@ func (e *MapEditor) drawMap(screen *ebiten.Image) {
 	}
 }
 
+// drawTileTexture draws a texture scaled to the tile at (x, y).
+func (e *MapEditor) drawTileTexture(screen, texture *ebiten.Image, x, y int, alpha float32) {
+	if texture == nil {
+		return
+	}
+	op := &ebiten.DrawImageOptions{}
+
+	// Apply zoom scaling (tile textures can have any size)
+	w, h := texture.Bounds().Dx(), texture.Bounds().Dy()
+	op.GeoM.Scale(float64(tileSize)/float64(w)*e.camera.Zoom, float64(tileSize)/float64(h)*e.camera.Zoom)
+
+	// Center tiles like in the main game (tile spans center +/- tileSize/2)
+	worldX := float64(x*tileSize - tileSize/2)
+	worldY := float64(y*tileSize - tileSize/2)
+	screenX := (worldX-e.camera.X)*e.camera.Zoom + float64(windowWidth)/2
+	screenY := (worldY-e.camera.Y)*e.camera.Zoom + float64(windowHeight)/2
+
+	op.GeoM.Translate(screenX, screenY)
+	op.ColorScale.ScaleAlpha(alpha)
+	screen.DrawImage(texture, op)
+}
+
 func (e *MapEditor) drawNodes(screen *ebiten.Image) {
 	// Draw paths first (so they appear behind nodes)
 	for _, path := range e.mapData.Paths {
@@ -563,6 +647,56 @@ func (e *MapEditor) drawNodes(screen *ebiten.Image) {
 	}
 }
 
+// loadMap (re)loads the map from mapPath. Chunked maps are paged in as the
+// camera moves instead.
+func (e *MapEditor) loadMap() error {
+	if mapio.IsChunkedMap(e.mapPath) {
+		pager, m, err := openChunkPager(e.mapPath)
+		if err != nil {
+			return err
+		}
+		e.pager, e.mapData = pager, m
+		e.tools.editable = pager.editable
+		return nil
+	}
+	m, err := mapio.LoadMapFromFile(e.mapPath)
+	if err != nil {
+		return err
+	}
+	e.pager, e.mapData = nil, m
+	e.tools.editable = nil
+	return nil
+}
+
+// saveMap writes the map back to mapPath, only the changed chunks of a
+// chunked map.
+func (e *MapEditor) saveMap() error {
+	if e.pager != nil {
+		n, err := e.pager.save(e.mapData)
+		fmt.Printf("Saved %d changed chunks\n", n)
+		return err
+	}
+	return mapio.SaveMapToFile(e.mapData, e.mapPath)
+}
+
+// generateNavGraph replaces the nodes & paths with ones generated from the
+// walkable tiles (mapio.GenerateNavGraph). Like node edits it can't be
+// undone, reload the map to get the old graph back.
+func (e *MapEditor) generateNavGraph() error {
+	if e.pager != nil {
+		// the generator needs the tiles of the whole map
+		if err := e.pager.loadAll(e.mapData); err != nil {
+			return err
+		}
+	}
+	nodes, paths := e.mapData.GenerateNavGraph(mapio.NavGenOptions{Tiles: e.assets.Tiles()})
+	e.tools.selectedNodeID = -1
+	e.tools.creatingPath = false
+	fmt.Printf("Generated nav graph: %d nodes, %d paths\n", nodes, paths)
+	e.ui.ShowStatus(fmt.Sprintf("Generated %d nodes, %d paths", nodes, paths) + e.checkMap())
+	return nil
+}
+
 func (e *MapEditor) Layout(outsideWidth, outsideHeight int) (screenWidth, screenHeight int) {
 	return windowWidth, windowHeight
 }
@@ -573,16 +707,19 @@ func main() {
 	// Initialize components
 	editor.camera = NewCamera()
 	editor.mapData = mapio.NewMapData(150, 100) // Width x Height from your RPG
-	editor.ui = NewUI()
 	editor.tools = NewToolSystem()
 	editor.assets = NewAssetManager()
 
-	// Try to load existing map from the main project
-	if mapData, err := mapio.LoadMapFromFile("../map.txt"); err != nil {
+	// The main project's map unless another one (file or .chunks directory) is given
+	editor.mapPath = "../map.txt"
+	if len(os.Args) > 1 {
+		editor.mapPath = os.Args[1]
+	}
+	if err := editor.loadMap(); err != nil {
 		fmt.Printf("Could not load existing map: %v\n", err)
 		fmt.Println("Starting with empty map...")
 	} else {
-		editor.mapData = mapData
+		mapData := editor.mapData
 		fmt.Printf("Loaded map: %dx%d with %d nodes, %d paths, %d sprites\n",
 			mapData.Width, mapData.Height, len(mapData.Nodes), len(mapData.Paths), len(mapData.Sprites))
 	}
@@ -592,6 +729,8 @@ func main() {
 		fmt.Printf("Could not load assets: %v\n", err)
 		fmt.Println("Using fallback colors...")
 	}
+	// the tile palette comes from the loaded tile definitions
+	editor.ui = NewUI(editor.assets.Tiles())
 
 	ebiten.SetWindowSize(windowWidth, windowHeight)
 	ebiten.SetWindowTitle("RPG Map Editor")
@@ -26,6 +26,7 @@ type Action struct {
 
 // TileChange represents a single tile modification
 type TileChange struct {
+	Layer    string // mapio layer name
 	X, Y     int
 	OldValue int
 	NewValue int
@@ -33,6 +34,7 @@ type TileChange struct {
 
 type ToolSystem struct {
 	currentTool            ToolType
+	layer                  string // layer painted by Paint/Bucket
 	painting               bool
 	lastPaintX, lastPaintY int
 
@@ -51,11 +53,16 @@ type ToolSystem struct {
 
 	// Spawner editing
 	selectedSpawner int
+
+	// editable reports whether a tile can be changed, nil = all of them
+	// (a chunked map only allows the chunks that are paged in)
+	editable func(x, y int) bool
 }
 
 func NewToolSystem() ToolSystem {
 	return ToolSystem{
 		currentTool:     ToolPaint,
+		layer:           mapio.LayerGround,
 		painting:        false,
 		selectedNodeID:  -1,
 		creatingPath:    false,
@@ -102,11 +109,11 @@ func (t *ToolSystem) PaintTile(mapData *mapio.MapData, tileX, tileY, tileType in
 	switch t.currentTool {
 	case ToolPaint:
 		// Record single tile change for undo
-		oldValue := mapData.GetTile(tileX, tileY)
-		if oldValue != tileType {
-			changes := []TileChange{{X: tileX, Y: tileY, OldValue: oldValue, NewValue: tileType}}
+		oldValue := mapData.GetLayerTile(t.layer, tileX, tileY)
+		if oldValue != tileType && tileX >= 0 && tileX < mapData.Width && tileY >= 0 && tileY < mapData.Height {
+			changes := []TileChange{{Layer: t.layer, X: tileX, Y: tileY, OldValue: oldValue, NewValue: tileType}}
 			t.addToHistory(Action{ActionType: "paint", Changes: changes})
-			mapData.SetTile(tileX, tileY, tileType)
+			mapData.SetLayerTile(t.layer, tileX, tileY, tileType)
 		}
 	case ToolBucket:
 		// Bucket fill - we'll collect all changes first
@@ -123,6 +130,11 @@ func (t *ToolSystem) SetTool(tool ToolType) {
 	t.currentTool = tool
 }
 
+// SetLayer changes the layer Paint and Bucket work on
+func (t *ToolSystem) SetLayer(layer string) {
+	t.layer = layer
+}
+
 // GetCurrentTool returns the current tool
 func (t *ToolSystem) GetCurrentTool() ToolType {
 	return t.currentTool
@@ -151,7 +163,7 @@ func (t *ToolSystem) GetToolName() string {
 // bucketFill implements flood fill algorithm - now just for calculating changes
 func (t *ToolSystem) getBucketFillChanges(mapData *mapio.MapData, startX, startY, newTileType int) []TileChange {
 	// Get the original tile type at the starting position
-	originalTileType := mapData.GetTile(startX, startY)
+	originalTileType := mapData.GetLayerTile(t.layer, startX, startY)
 
 	// If the new tile type is the same as the original, do nothing
 	if originalTileType == newTileType {
@@ -184,6 +196,9 @@ func (t *ToolSystem) getBucketFillChanges(mapData *mapio.MapData, startX, startY
 		if x < 0 || x >= mapData.Width || y < 0 || y >= mapData.Height {
 			continue
 		}
+		if t.editable != nil && !t.editable(x, y) {
+			continue
+		}
 
 		// Check if already visited
 		if visited[y][x] {
@@ -191,7 +206,7 @@ func (t *ToolSystem) getBucketFillChanges(mapData *mapio.MapData, startX, startY
 		}
 
 		// Check if this tile matches the original type
-		if mapData.GetTile(x, y) != originalTileType {
+		if mapData.GetLayerTile(t.layer, x, y) != originalTileType {
 			continue
 		}
 
@@ -200,7 +215,9 @@ func (t *ToolSystem) getBucketFillChanges(mapData *mapio.MapData, startX, startY
 
 		// Record this change
 		changes = append(changes, TileChange{
-			X: x, Y: y,
+			Layer:    t.layer,
+			X:        x,
+			Y:        y,
 			OldValue: originalTileType,
 			NewValue: newTileType,
 		})
@@ -238,7 +255,7 @@ func (t *ToolSystem) addToHistory(action Action) {
 // applyChanges applies a list of tile changes to the map
 func (t *ToolSystem) applyChanges(mapData *mapio.MapData, changes []TileChange) {
 	for _, change := range changes {
-		mapData.SetTile(change.X, change.Y, change.NewValue)
+		mapData.SetLayerTile(change.Layer, change.X, change.Y, change.NewValue)
 	}
 }
 
@@ -252,7 +269,7 @@ func (t *ToolSystem) Undo(mapData *mapio.MapData) bool {
 
 	// Revert all changes in reverse order
 	for _, change := range action.Changes {
-		mapData.SetTile(change.X, change.Y, change.OldValue)
+		mapData.SetLayerTile(change.Layer, change.X, change.Y, change.OldValue)
 	}
 
 	t.historyIndex--
@@ -270,7 +287,7 @@ func (t *ToolSystem) Redo(mapData *mapio.MapData) bool {
 
 	// Apply all changes
 	for _, change := range action.Changes {
-		mapData.SetTile(change.X, change.Y, change.NewValue)
+		mapData.SetLayerTile(change.Layer, change.X, change.Y, change.NewValue)
 	}
 
 	return true
@@ -285,7 +302,7 @@ func (t *ToolSystem) CanUndo() bool {
 func (t *ToolSystem) HandleNodeTool(mapData *mapio.MapData, worldX, worldY float64, leftClick, rightClick bool) {
 	if leftClick {
 		// Check if clicking on existing node
-		nodeID := t.findNodeAtPosition(mapData, worldX, worldY, 16.0) // 16 pixel tolerance
+		nodeID := findNodeAt(mapData, worldX, worldY)
 
 		if nodeID >= 0 {
 			// Select existing node
@@ -305,10 +322,11 @@ func (t *ToolSystem) HandleNodeTool(mapData *mapio.MapData, worldX, worldY float
 	}
 }
 
-// HandlePathTool handles path creation between nodes
+// HandlePathTool handles path creation between nodes: click two nodes to
+// connect them, right click the second one instead to disconnect them.
 func (t *ToolSystem) HandlePathTool(mapData *mapio.MapData, worldX, worldY float64, leftClick, rightClick bool) {
 	if leftClick {
-		nodeID := t.findNodeAtPosition(mapData, worldX, worldY, 16.0)
+		nodeID := findNodeAt(mapData, worldX, worldY)
 
 		if nodeID >= 0 {
 			if !t.creatingPath {
@@ -317,20 +335,8 @@ func (t *ToolSystem) HandlePathTool(mapData *mapio.MapData, worldX, worldY float
 				t.creatingPath = true
 				t.selectedNodeID = nodeID
 			} else {
-				// Complete path creation
-				if nodeID != t.pathStartNodeID {
-					// Calculate distance as cost
-					nodeA := mapData.FindNodeByID(t.pathStartNodeID)
-					nodeB := mapData.FindNodeByID(nodeID)
-					if nodeA != nil && nodeB != nil {
-						dx := nodeB.Pos.X - nodeA.Pos.X
-						dy := nodeB.Pos.Y - nodeA.Pos.Y
-						cost := float32(dx*dx + dy*dy)           // Squared distance for cost
-						cost = float32(math.Sqrt(float64(cost))) // Actual distance
-
-						mapData.AddPath(t.pathStartNodeID, nodeID, cost)
-					}
-				}
+				// Complete path creation, the cost is the distance
+				mapData.AddPath(t.pathStartNodeID, nodeID)
 				t.creatingPath = false
 				t.pathStartNodeID = -1
 			}
@@ -338,6 +344,11 @@ func (t *ToolSystem) HandlePathTool(mapData *mapio.MapData, worldX, worldY float
 	}
 
 	if rightClick {
+		if t.creatingPath {
+			if nodeID := findNodeAt(mapData, worldX, worldY); nodeID >= 0 {
+				mapData.RemovePath(t.pathStartNodeID, nodeID)
+			}
+		}
 		// Cancel path creation
 		t.creatingPath = false
 		t.pathStartNodeID = -1
@@ -444,16 +455,11 @@ func (t *ToolSystem) GetSelectedSpawner() int { return t.selectedSpawner }
 
 func (t *ToolSystem) GetSelectedNPC() int { return t.selectedNPC }
 
-// findNodeAtPosition finds a node within tolerance distance of the given position
-func (t *ToolSystem) findNodeAtPosition(mapData *mapio.MapData, worldX, worldY, tolerance float64) int {
-	for _, node := range mapData.Nodes {
-		dx := float64(node.Pos.X) - worldX
-		dy := float64(node.Pos.Y) - worldY
-		distance := math.Sqrt(dx*dx + dy*dy)
-
-		if distance <= tolerance {
-			return node.ID
-		}
+// findNodeAt returns the id of the node under the cursor, -1 if none.
+func findNodeAt(mapData *mapio.MapData, worldX, worldY float64) int {
+	// 16 pixel tolerance
+	if n := mapData.FindNodeAt(mapio.Pos{X: float32(worldX), Y: float32(worldY)}, 16); n != nil {
+		return n.ID
 	}
 	return -1
 }
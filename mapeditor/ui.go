@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"image/color"
+	"rpg/mapio"
 
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
@@ -12,14 +13,24 @@ import (
 
 type UI struct {
 	selectedTileType int
+	selectedLayer    int // index into mapio.LayerNames
 	showGrid         bool
-	tileButtons      [4]Button
-	toolButtons      [6]Button // added Spawner
+	tiles            *mapio.TileSet
+	palette          []paletteEntry // tiles offered for the edited layer
+	tileButtons      []Button       // one per palette entry
+	toolButtons      [6]Button      // added Spawner
 	selectedTool     ToolType
 	statusMessage    string
 	statusTimer      int
 }
 
+// paletteEntry is a tile value that can be painted on the edited layer.
+type paletteEntry struct {
+	value int
+	name  string
+	color color.RGBA
+}
+
 type Button struct {
 	X, Y, W, H int
 	Text       string
@@ -28,52 +39,39 @@ type Button struct {
 	Hovered    bool
 }
 
-func NewUI() UI {
-	ui := UI{
-		selectedTileType: 2, // Start with grass/plains
-		showGrid:         true,
-		selectedTool:     ToolPaint, // Start with paint tool
-	}
-
-	// Create tile type buttons
-	buttonWidth := 80
-	buttonHeight := 60
-	startX := 20
-	startY := 20
-
-	tileNames := []string{"Void", "Mountain", "Plains", "Dry"}
-	tileColors := []color.RGBA{
-		voidColor,
-		darkGray,
-		lightGreen,
-		lightBrown,
-	}
+const (
+	buttonWidth      = 80
+	tileButtonHeight = 36
+	toolButtonHeight = 30
+	panelX           = 20
+	panelY           = 20
+)
 
-	for i := 0; i < 4; i++ {
-		ui.tileButtons[i] = Button{
-			X:     startX,
-			Y:     startY + i*(buttonHeight+10),
-			W:     buttonWidth,
-			H:     buttonHeight,
-			Text:  tileNames[i],
-			Color: tileColors[i],
-		}
+func NewUI(tiles *mapio.TileSet) UI {
+	ui := UI{
+		showGrid:     true,
+		tiles:        tiles,
+		selectedTool: ToolPaint, // Start with paint tool
 	}
 
-	// Create tool buttons (add NPC)
+	// Create tool buttons (add NPC), placed below the tile buttons by updateTileButtons
 	toolNames := []string{"Paint", "Bucket", "Node", "Path", "NPC", "Spawner"}
-	toolY := startY + 4*(buttonHeight+10) + 20 // Below tile buttons
-
 	for i := 0; i < len(toolNames); i++ {
 		ui.toolButtons[i] = Button{
-			X:     startX,
-			Y:     toolY + i*(buttonHeight/2+5),
 			W:     buttonWidth,
-			H:     buttonHeight / 2,
+			H:     toolButtonHeight,
 			Text:  toolNames[i],
 			Color: lightGray,
 		}
 	}
+	ui.updateTileButtons()
+
+	// Start with grass/plains if there is such a tile
+	for i, p := range ui.palette {
+		if p.value == 2 {
+			ui.selectedTileType = i
+		}
+	}
 
 	return ui
 }
@@ -90,7 +88,7 @@ func (ui *UI) Update() {
 	}
 
 	// Update tile buttons
-	for i := 0; i < 4; i++ {
+	for i := range ui.tileButtons {
 		btn := &ui.tileButtons[i]
 
 		// Check if mouse is over button
@@ -125,18 +123,11 @@ func (ui *UI) Update() {
 
 	// Handle keyboard shortcuts only when Ctrl held so normal typing works in editors
 	if ebiten.IsKeyPressed(ebiten.KeyControl) {
-		// Tile selections
-		if inpututil.IsKeyJustPressed(ebiten.Key0) {
-			ui.selectedTileType = 0
-		}
-		if inpututil.IsKeyJustPressed(ebiten.Key1) {
-			ui.selectedTileType = 1
-		}
-		if inpututil.IsKeyJustPressed(ebiten.Key2) {
-			ui.selectedTileType = 2
-		}
-		if inpututil.IsKeyJustPressed(ebiten.Key3) {
-			ui.selectedTileType = 3
+		// Tile selections, by palette position
+		for i := 0; i < len(ui.palette) && i < 10; i++ {
+			if inpututil.IsKeyJustPressed(ebiten.Key0 + ebiten.Key(i)) {
+				ui.selectedTileType = i
+			}
 		}
 		// Tool selections
 		if inpututil.IsKeyJustPressed(ebiten.KeyP) {
@@ -145,7 +136,7 @@ func (ui *UI) Update() {
 		if inpututil.IsKeyJustPressed(ebiten.KeyB) {
 			ui.selectedTool = ToolBucket
 		}
-		if inpututil.IsKeyJustPressed(ebiten.KeyN) {
+		if !ebiten.IsKeyPressed(ebiten.KeyShift) && inpututil.IsKeyJustPressed(ebiten.KeyN) {
 			ui.selectedTool = ToolNode
 		}
 		if inpututil.IsKeyJustPressed(ebiten.KeyM) {
@@ -161,6 +152,59 @@ func (ui *UI) Update() {
 		if inpututil.IsKeyJustPressed(ebiten.KeyG) {
 			ui.showGrid = !ui.showGrid
 		}
+		// Cycle the edited layer
+		if inpututil.IsKeyJustPressed(ebiten.KeyL) {
+			ui.selectedLayer = (ui.selectedLayer + 1) % len(mapio.LayerNames)
+			ui.updateTileButtons()
+			ui.ShowStatus("Layer: " + ui.GetSelectedLayer())
+		}
+	}
+}
+
+// collision values aren't tiles, so their palette is fixed
+var collisionPalette = []paletteEntry{
+	{mapio.CollisionDefault, "Auto", white},
+	{mapio.CollisionBlocked, "Block", blockedColor},
+	{mapio.CollisionWalkable, "Walk", walkableColor},
+}
+
+// updateTileButtons rebuilds the palette for the edited layer from the tile
+// definitions and lays out the buttons.
+func (ui *UI) updateTileButtons() {
+	layer := ui.GetSelectedLayer()
+	ui.palette = ui.palette[:0]
+	switch layer {
+	case mapio.LayerCollision:
+		ui.palette = append(ui.palette, collisionPalette...)
+	case mapio.LayerGround:
+	default:
+		ui.palette = append(ui.palette, paletteEntry{0, "Erase", white})
+	}
+	if layer != mapio.LayerCollision {
+		for _, def := range ui.tiles.ForLayer(layer) {
+			ui.palette = append(ui.palette, paletteEntry{def.ID, def.Name, def.Color()})
+		}
+	}
+
+	ui.tileButtons = ui.tileButtons[:0]
+	for i, p := range ui.palette {
+		ui.tileButtons = append(ui.tileButtons, Button{
+			X:     panelX,
+			Y:     panelY + i*(tileButtonHeight+5),
+			W:     buttonWidth,
+			H:     tileButtonHeight,
+			Text:  p.name,
+			Color: p.color,
+		})
+	}
+	if ui.selectedTileType >= len(ui.palette) {
+		ui.selectedTileType = 0
+	}
+
+	toolY := panelY + len(ui.palette)*(tileButtonHeight+5) + 15 // Below tile buttons
+	for i := range ui.toolButtons {
+		ui.toolButtons[i].X = panelX
+		ui.toolButtons[i].Y = toolY + i*(toolButtonHeight+5)
 	}
 }
 
@@ -203,7 +247,7 @@ func (ui *UI) Draw(screen *ebiten.Image) {
 
 		// Draw button text
 		textX := btn.X + 5
-		textY := btn.Y + 15
+		textY := btn.Y + 3
 		ebitenutil.DebugPrintAt(screen, btn.Text, textX, textY)
 		ebitenutil.DebugPrintAt(screen, fmt.Sprintf("(%d)", i), textX, textY+15)
 	}
@@ -242,7 +286,7 @@ func (ui *UI) Draw(screen *ebiten.Image) {
 	// Draw instructions
 	instructionsY := 420
 	ebitenutil.DebugPrintAt(screen, "Controls:", 20, instructionsY)
-	ebitenutil.DebugPrintAt(screen, "0-3: Select tile", 20, instructionsY+15)
+	ebitenutil.DebugPrintAt(screen, "0-9: Select tile", 20, instructionsY+15)
 	ebitenutil.DebugPrintAt(screen, "P: Paint tool", 20, instructionsY+30)
 	ebitenutil.DebugPrintAt(screen, "B: Bucket tool", 20, instructionsY+45)
 	ebitenutil.DebugPrintAt(screen, "N: Node tool", 20, instructionsY+60)
@@ -254,6 +298,9 @@ func (ui *UI) Draw(screen *ebiten.Image) {
 	ebitenutil.DebugPrintAt(screen, "MClick: Pan camera", 20, instructionsY+150)
 	ebitenutil.DebugPrintAt(screen, "Wheel: Zoom", 20, instructionsY+165)
 	ebitenutil.DebugPrintAt(screen, "G: Toggle grid", 20, instructionsY+180)
+	ebitenutil.DebugPrintAt(screen, "L: Layer ("+ui.GetSelectedLayer()+")", 20, instructionsY+240)
+	ebitenutil.DebugPrintAt(screen, "Ctrl+K: Check map", 20, instructionsY+255)
+	ebitenutil.DebugPrintAt(screen, "Ctrl+Shift+N: Generate nav", 20, instructionsY+270)
 	ebitenutil.DebugPrintAt(screen, "Ctrl+Shift+S: Save", 20, instructionsY+195)
 	ebitenutil.DebugPrintAt(screen, "Ctrl+Z: Undo", 20, instructionsY+210)
 	ebitenutil.DebugPrintAt(screen, "Ctrl+Y: Redo", 20, instructionsY+225)
@@ -291,8 +338,17 @@ func (ui *UI) Draw(screen *ebiten.Image) {
 	}
 }
 
+// GetSelectedTileType returns the tile value to paint on the edited layer
 func (ui *UI) GetSelectedTileType() int {
-	return ui.selectedTileType
+	if ui.selectedTileType < 0 || ui.selectedTileType >= len(ui.palette) {
+		return 0
+	}
+	return ui.palette[ui.selectedTileType].value
+}
+
+// GetSelectedLayer returns the mapio layer name being edited
+func (ui *UI) GetSelectedLayer() string {
+	return mapio.LayerNames[ui.selectedLayer]
 }
 
 func (ui *UI) GetSelectedTool() ToolType {
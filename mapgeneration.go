@@ -1,5 +1,7 @@
 package main
 
+import "rpg/mapio"
+
 const (
 	multipler_hillchance     = 1.1
 	multipler_forestchance   = 1.1
@@ -16,9 +18,11 @@ func createMap(_height int) gamemap {
 
 		m gamemap
 	)
+	rnd := game.rng.stream(rngWorld)
 
 	m.height = _height
 	m.width = int(float32(_height) * 1.77777777778)
+	md := mapio.NewMapData(m.width, m.height)
 
 	// 0 = not decided, 1 = mountains, 2 = plains, 3 = hills, 4 = forests
 	// Edges are mountains
@@ -28,7 +32,7 @@ func createMap(_height int) gamemap {
 			if i != 0 && i != m.height-1 && j != 0 && j != m.width-1 {
 
 				//on the top
-				switch m.data[i][j-1] {
+				switch md.Tiles[i][j-1] {
 				case 3:
 					hillchance *= multipler_general_top
 				case 4:
@@ -36,7 +40,7 @@ func createMap(_height int) gamemap {
 				}
 
 				//on the bottom
-				switch m.data[i-1][j] {
+				switch md.Tiles[i-1][j] {
 				case 3:
 					hillchance *= multipler_general_bottom
 				case 4:
@@ -46,32 +50,33 @@ func createMap(_height int) gamemap {
 			}
 
 			if i == 0 || i == m.height-1 || j == 0 || j == m.width-1 {
-				m.data[i][j] = 1
-			} else if calcChance(float64(forestchance / 2)) {
-				m.data[i][j] = 4 // Forest
+				md.Tiles[i][j] = 1
+			} else if calcChance(rnd, float64(forestchance/2)) {
+				md.Tiles[i][j] = 4 // Forest
 
 				hillchance *= multipler_hillchance
 				mountainchance *= multipler_mountainchance
 				forestchance = 1
 
-			} else if calcChance(float64(hillchance) / 6) {
-				m.data[i][j] = 3 // Hill
+			} else if calcChance(rnd, float64(hillchance)/6) {
+				md.Tiles[i][j] = 3 // Hill
 
 				forestchance *= multipler_forestchance
 				mountainchance *= multipler_mountainchance
 				hillchance = 1
 
-			} else if calcChance(float64(mountainchance)) {
-				m.data[i][j] = 1 // Mountain
+			} else if calcChance(rnd, float64(mountainchance)) {
+				md.Tiles[i][j] = 1 // Mountain
 
 				forestchance *= multipler_forestchance
 				hillchance *= multipler_hillchance
 				mountainchance = 1
 			} else {
-				m.data[i][j] = 2 // Plains
+				md.Tiles[i][j] = 2 // Plains
 			}
 		}
 	}
 
+	m.chunks = createChunkCache(mapio.NewMemorySource(md, mapio.DefaultChunkSize))
 	return m
 }
@@ -0,0 +1,383 @@
+package mapio
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Chunked maps
+//
+// Big maps are split into square chunks so the game and the editor only
+// need the part around the camera. On disk a chunked map is a directory
+// (name.chunks) holding
+//
+//	map.json           the map without tiles (objects, size, chunk size)
+//	chunks/X_Y.json    the tiles of chunk (X, Y), all layers
+//
+// Chunks that are completely empty (all 0) have no file.
+
+// DefaultChunkSize is the chunk edge length in tiles.
+const DefaultChunkSize = 32
+
+const (
+	chunkHeaderFile = "map.json"
+	chunkDir        = "chunks"
+)
+
+// Chunk is a square piece of a map. X and Y are chunk coordinates, the
+// chunk covers tiles X*Size .. X*Size+Size-1 (same for Y). Tiles past the
+// edge of the map are 0.
+type Chunk struct {
+	X    int `json:"x"`
+	Y    int `json:"y"`
+	Size int `json:"-"`
+	// ground layer
+	Tiles [][]int `json:"tiles"`
+	// the other layers, only those with something in this chunk
+	Layers []TileLayer `json:"layers"`
+}
+
+// NewChunk returns an empty chunk.
+func NewChunk(cx, cy, size int) *Chunk {
+	return &Chunk{X: cx, Y: cy, Size: size, Tiles: newGrid(size, size), Layers: []TileLayer{}}
+}
+
+// ChunkOf returns the chunk containing tile (x, y).
+func ChunkOf(x, y, size int) (cx, cy int) {
+	return floorDiv(x, size), floorDiv(y, size)
+}
+
+func floorDiv(a, b int) int {
+	q := a / b
+	if a%b != 0 && a < 0 {
+		q--
+	}
+	return q
+}
+
+// Origin returns the map coordinates of the chunk's top left tile.
+func (c *Chunk) Origin() (x, y int) {
+	return c.X * c.Size, c.Y * c.Size
+}
+
+// LayerTiles returns the grid of a layer, nil if the chunk has nothing on it.
+func (c *Chunk) LayerTiles(name string) [][]int {
+	if name == LayerGround {
+		return c.Tiles
+	}
+	for i := range c.Layers {
+		if c.Layers[i].Name == name {
+			return c.Layers[i].Tiles
+		}
+	}
+	return nil
+}
+
+// Get returns the tile of a layer at chunk local coordinates, 0 if out of
+// the chunk or the layer is empty.
+func (c *Chunk) Get(layer string, x, y int) int {
+	tiles := c.LayerTiles(layer)
+	if y < 0 || y >= len(tiles) || x < 0 || x >= len(tiles[y]) {
+		return 0
+	}
+	return tiles[y][x]
+}
+
+// Empty reports whether every tile of every layer is 0.
+func (c *Chunk) Empty() bool {
+	if !layerIsEmpty(c.Tiles) {
+		return false
+	}
+	for _, l := range c.Layers {
+		if !layerIsEmpty(l.Tiles) {
+			return false
+		}
+	}
+	return true
+}
+
+// Equal reports whether both chunks hold the same tiles (an empty layer
+// equals a missing one).
+func (c *Chunk) Equal(o *Chunk) bool {
+	if c.X != o.X || c.Y != o.Y || c.Size != o.Size {
+		return false
+	}
+	for _, name := range LayerNames {
+		for y := 0; y < c.Size; y++ {
+			for x := 0; x < c.Size; x++ {
+				if c.Get(name, x, y) != o.Get(name, x, y) {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+// ChunkCount returns how many chunks the map spans in each direction.
+func (m *MapData) ChunkCount(size int) (w, h int) {
+	return (m.Width + size - 1) / size, (m.Height + size - 1) / size
+}
+
+// ExtractChunk copies a chunk out of the map.
+func (m *MapData) ExtractChunk(cx, cy, size int) *Chunk {
+	c := NewChunk(cx, cy, size)
+	ox, oy := c.Origin()
+	copyIn := func(dst, src [][]int) {
+		for y := range dst {
+			if oy+y < 0 || oy+y >= len(src) {
+				continue
+			}
+			row := src[oy+y]
+			for x := range dst[y] {
+				if ox+x >= 0 && ox+x < len(row) {
+					dst[y][x] = row[ox+x]
+				}
+			}
+		}
+	}
+	copyIn(c.Tiles, m.Tiles)
+	for _, l := range m.Layers {
+		tiles := newGrid(size, size)
+		copyIn(tiles, l.Tiles)
+		if !layerIsEmpty(tiles) {
+			c.Layers = append(c.Layers, TileLayer{Name: l.Name, Tiles: tiles})
+		}
+	}
+	return c
+}
+
+// ApplyChunk writes a chunk's tiles into the map. Tiles outside the map are
+// dropped; layers the chunk doesn't have are cleared in its area.
+func (m *MapData) ApplyChunk(c *Chunk) {
+	ox, oy := c.Origin()
+	for _, name := range LayerNames {
+		if c.LayerTiles(name) == nil && m.LayerTiles(name) == nil {
+			continue
+		}
+		for y := 0; y < c.Size; y++ {
+			for x := 0; x < c.Size; x++ {
+				if name == LayerGround {
+					m.SetTile(ox+x, oy+y, c.Get(name, x, y))
+				} else {
+					m.SetLayerTile(name, ox+x, oy+y, c.Get(name, x, y))
+				}
+			}
+		}
+	}
+}
+
+// ChunkSource hands out the chunks of a map. MemorySource serves a map that
+// is already loaded, ChunkStore reads them from a chunked map directory.
+type ChunkSource interface {
+	ChunkSize() int
+	// LoadChunk returns chunk (cx, cy); chunks with nothing in them come
+	// back empty, not as an error.
+	LoadChunk(cx, cy int) (*Chunk, error)
+}
+
+// MemorySource serves chunks of a map held in memory. The map stays
+// loaded as a whole, use a ChunkStore when memory has to stay flat.
+type MemorySource struct {
+	Map  *MapData
+	Size int
+}
+
+// NewMemorySource chunks a loaded map.
+func NewMemorySource(m *MapData, size int) *MemorySource {
+	return &MemorySource{Map: m, Size: size}
+}
+
+func (s *MemorySource) ChunkSize() int { return s.Size }
+
+func (s *MemorySource) LoadChunk(cx, cy int) (*Chunk, error) {
+	return s.Map.ExtractChunk(cx, cy, s.Size), nil
+}
+
+// ChunkStore is a chunked map directory. Header is the map without tiles
+// (Width/Height are the full map size).
+type ChunkStore struct {
+	Dir    string
+	Size   int
+	Header *MapData
+}
+
+// IsChunkedMap reports whether path is a chunked map directory.
+func IsChunkedMap(path string) bool {
+	st, err := os.Stat(filepath.Join(path, chunkHeaderFile))
+	return err == nil && !st.IsDir()
+}
+
+// OpenChunkStore reads the header of a chunked map. Chunks are loaded with
+// LoadChunk, or all at once with LoadAll.
+func OpenChunkStore(dir string) (*ChunkStore, error) {
+	data, err := os.ReadFile(filepath.Join(dir, chunkHeaderFile))
+	if err != nil {
+		return nil, fmt.Errorf("error opening chunked map: %v", err)
+	}
+	var header struct {
+		ChunkSize int `json:"chunk_size"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, fmt.Errorf("invalid chunked map header: %v", err)
+	}
+	if header.ChunkSize <= 0 {
+		return nil, fmt.Errorf("chunked map header has no chunk_size")
+	}
+	m, err := DecodeJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	// the header never has tiles, the chunks do
+	m.Tiles = nil
+	m.Layers = []TileLayer{}
+	return &ChunkStore{Dir: dir, Size: header.ChunkSize, Header: m}, nil
+}
+
+// CreateChunkStore starts an empty chunked map directory for m (only its
+// header is written).
+func CreateChunkStore(dir string, m *MapData, size int) (*ChunkStore, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("invalid chunk size %d", size)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, chunkDir), 0o755); err != nil {
+		return nil, err
+	}
+	s := &ChunkStore{Dir: dir, Size: size}
+	if err := s.SaveHeader(m); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *ChunkStore) ChunkSize() int { return s.Size }
+
+func (s *ChunkStore) chunkPath(cx, cy int) string {
+	return filepath.Join(s.Dir, chunkDir, fmt.Sprintf("%d_%d.json", cx, cy))
+}
+
+// LoadChunk reads a chunk, a missing file is an empty chunk.
+func (s *ChunkStore) LoadChunk(cx, cy int) (*Chunk, error) {
+	data, err := os.ReadFile(s.chunkPath(cx, cy))
+	if os.IsNotExist(err) {
+		return NewChunk(cx, cy, s.Size), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	c := NewChunk(cx, cy, s.Size)
+	var f Chunk
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("chunk %d,%d: %v", cx, cy, err)
+	}
+	// copy into full size grids so short or ragged files can't break lookups
+	fit := func(dst, src [][]int) {
+		for y := 0; y < len(dst) && y < len(src); y++ {
+			copy(dst[y], src[y])
+		}
+	}
+	fit(c.Tiles, f.Tiles)
+	for _, l := range f.Layers {
+		if !IsLayerName(l.Name) || l.Name == LayerGround {
+			continue
+		}
+		tiles := newGrid(s.Size, s.Size)
+		fit(tiles, l.Tiles)
+		c.Layers = append(c.Layers, TileLayer{Name: l.Name, Tiles: tiles})
+	}
+	return c, nil
+}
+
+// SaveChunk writes a chunk (removing its file if it's empty).
+func (s *ChunkStore) SaveChunk(c *Chunk) error {
+	path := s.chunkPath(c.X, c.Y)
+	if c.Empty() {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	data, err := marshalTiles(c)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// SaveHeader writes everything but the tiles of m.
+func (s *ChunkStore) SaveHeader(m *MapData) error {
+	h := *m
+	h.Tiles = nil
+	h.Layers = nil
+	var buf bytes.Buffer
+	if err := encodeJSON(&buf, jsonFile{Format: JSONFormatName, Version: JSONVersion, ChunkSize: s.Size, MapData: h}); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(s.Dir, chunkHeaderFile), buf.Bytes(), 0o644); err != nil {
+		return err
+	}
+	s.Header = &h
+	return nil
+}
+
+// LoadAll assembles the whole map.
+func (s *ChunkStore) LoadAll() (*MapData, error) {
+	m := *s.Header
+	m.Tiles = newGrid(m.Width, m.Height)
+	m.Layers = []TileLayer{}
+	cw, ch := m.ChunkCount(s.Size)
+	for cy := 0; cy < ch; cy++ {
+		for cx := 0; cx < cw; cx++ {
+			c, err := s.LoadChunk(cx, cy)
+			if err != nil {
+				return nil, err
+			}
+			m.ApplyChunk(c)
+		}
+	}
+	return &m, nil
+}
+
+// SaveChunked writes m as a chunked map directory. Chunk files of chunks
+// that are now empty or outside the map are removed.
+func SaveChunked(m *MapData, dir string, size int) error {
+	s, err := CreateChunkStore(dir, m, size)
+	if err != nil {
+		return err
+	}
+	cw, ch := m.ChunkCount(size)
+	entries, err := os.ReadDir(filepath.Join(dir, chunkDir))
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		cx, cy, ok := parseChunkName(e.Name())
+		if ok && (cx < 0 || cy < 0 || cx >= cw || cy >= ch) {
+			os.Remove(filepath.Join(dir, chunkDir, e.Name()))
+		}
+	}
+	for cy := 0; cy < ch; cy++ {
+		for cx := 0; cx < cw; cx++ {
+			if err := s.SaveChunk(m.ExtractChunk(cx, cy, size)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// parseChunkName reads "X_Y.json".
+func parseChunkName(name string) (cx, cy int, ok bool) {
+	xs, ys, found := strings.Cut(strings.TrimSuffix(name, ".json"), "_")
+	if !found || !strings.HasSuffix(name, ".json") {
+		return 0, 0, false
+	}
+	cx, err1 := strconv.Atoi(xs)
+	cy, err2 := strconv.Atoi(ys)
+	return cx, cy, err1 == nil && err2 == nil
+}
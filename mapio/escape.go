@@ -0,0 +1,115 @@
+package mapio
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Text fields of the legacy format (NPC names & dialogue, sprite paths,
+// portal targets...) are written as they are when that's unambiguous, and
+// as a Go quoted string otherwise:
+//
+//	NPC, Old Man, 100.0, 200.0, -, -, Hello|"Left, right | centre"|"line\nbreak"
+//
+// A field is quoted if it is empty or "-", has commas, '|', surrounding
+// spaces, a leading '"' or anything unprintable. Only a '"' at the start of
+// a field opens a quote, so files written before quoting still read the same.
+
+// quoteText returns s ready to be written as a field.
+func quoteText(s string) string {
+	if plainText(s) {
+		return s
+	}
+	return strconv.Quote(s)
+}
+
+func plainText(s string) bool {
+	if s == "" || s == "-" || s != strings.TrimSpace(s) || s[0] == '"' {
+		return false
+	}
+	for _, r := range s {
+		if r == ',' || r == '|' || r == utf8.RuneError || !unicode.IsPrint(r) {
+			return false
+		}
+	}
+	return utf8.ValidString(s)
+}
+
+// unquoteText reads a field written by quoteText. A field that only looks
+// quoted (an old file with a '"' at the start of a line of dialogue) is
+// kept as it is.
+func unquoteText(field string) string {
+	field = strings.TrimSpace(field)
+	if strings.HasPrefix(field, `"`) {
+		if s, err := strconv.Unquote(field); err == nil {
+			return s
+		}
+	}
+	return field
+}
+
+// textField is quoteText for optional fields: empty is written as '-'.
+func textField(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return quoteText(s)
+}
+
+// parseTextField reads a field written by textField.
+func parseTextField(field string) string {
+	if strings.TrimSpace(field) == "-" {
+		return ""
+	}
+	return unquoteText(field)
+}
+
+// joinDialogues writes dialogue lines as one '|' separated field.
+func joinDialogues(lines []string) string {
+	quoted := make([]string, len(lines))
+	for i, l := range lines {
+		quoted[i] = quoteText(l)
+	}
+	return strings.Join(quoted, "|")
+}
+
+// splitDialogues reads a field written by joinDialogues.
+func splitDialogues(field string) []string {
+	lines := []string{}
+	if strings.TrimSpace(field) == "" {
+		return lines
+	}
+	for _, f := range splitFields(field, '|') {
+		lines = append(lines, unquoteText(f))
+	}
+	return lines
+}
+
+// splitFields splits a line at sep, except inside quoted fields.
+func splitFields(line string, sep byte) []string {
+	var fields []string
+	start := 0
+	for {
+		i := start
+		for i < len(line) && (line[i] == ' ' || line[i] == '\t') {
+			i++
+		}
+		if i < len(line) && line[i] == '"' {
+			// skip to the closing quote
+			for i++; i < len(line) && line[i] != '"'; i++ {
+				if line[i] == '\\' {
+					i++
+				}
+			}
+			i = min(i, len(line))
+		}
+		end := strings.IndexByte(line[i:], sep)
+		if end < 0 {
+			return append(fields, line[start:])
+		}
+		fields = append(fields, line[start:i+end])
+		start = i + end + 1
+	}
+}
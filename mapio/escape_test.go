@@ -0,0 +1,128 @@
+package mapio
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"testing/quick"
+)
+
+// textMap puts s in every text field of the legacy format.
+func textMap(s string) *MapData {
+	m := NewMapData(2, 2)
+	m.NPCs = []NPC{{
+		Name:       s,
+		Pos:        Pos{X: 30, Y: 60},
+		Dialogues:  []string{s, "plain", s},
+		VoiceKey:   s,
+		SpritePath: s,
+	}}
+	m.Portals = []Portal{{Name: s, Pos: Pos{X: 1, Y: 2}, Width: 30, Height: 30, TargetMap: s, TargetSpawn: s}}
+	m.SpawnPoints = []SpawnPoint{{Name: s, Pos: Pos{X: 3, Y: 4}}}
+	return m
+}
+
+func legacyRoundTrip(t *testing.T, m *MapData) *MapData {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := EncodeLegacy(&buf, m); err != nil {
+		t.Fatal(err)
+	}
+	got, err := DecodeLegacy(&buf)
+	if err != nil {
+		t.Fatalf("decode: %v\n%s", err, buf.String())
+	}
+	return got
+}
+
+// sameText compares the text fields (and the object counts) of two maps.
+func sameText(a, b *MapData) bool {
+	return reflect.DeepEqual(a.NPCs, b.NPCs) &&
+		reflect.DeepEqual(a.Portals, b.Portals) &&
+		reflect.DeepEqual(a.SpawnPoints, b.SpawnPoints)
+}
+
+func TestTextRoundTrip(t *testing.T) {
+	texts := []string{
+		"Old Man",
+		"",
+		"-",
+		" - ",
+		"Left, right",
+		"a|b|c",
+		`"quoted"`,
+		`say "hi", then leave`,
+		`"`,
+		`\`,
+		`trailing backslash \`,
+		"  leading spaces",
+		"trailing spaces  ",
+		"\ttab",
+		"line\nbreak",
+		"windows\r\nline",
+		"Grüße, 世界 | 🙂",
+		" nbsp",
+		"\x00nul",
+		"bad \xff utf-8",
+		"\xc3",
+		"NPC, Fake, 1.0, 2.0, -, -, injected",
+		"---NODES---",
+	}
+	for _, s := range texts {
+		m := textMap(s)
+		if got := legacyRoundTrip(t, m); !sameText(m, got) {
+			t.Errorf("legacy %q: got NPCs %+v portals %+v spawns %+v", s, got.NPCs, got.Portals, got.SpawnPoints)
+		}
+	}
+}
+
+func TestTextRoundTripQuick(t *testing.T) {
+	// random bytes, so invalid UTF-8 too
+	f := func(name, line []byte) bool {
+		m := textMap(string(name))
+		m.NPCs[0].Dialogues = []string{string(line), string(name)}
+		return sameText(m, legacyRoundTrip(t, m))
+	}
+	if err := quick.Check(f, &quick.Config{MaxCount: 2000}); err != nil {
+		t.Error(err)
+	}
+	// random runes
+	g := func(s string) bool {
+		m := textMap(s)
+		return sameText(m, legacyRoundTrip(t, m))
+	}
+	if err := quick.Check(g, &quick.Config{MaxCount: 2000}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestEmptyDialogues(t *testing.T) {
+	for _, d := range [][]string{{}, {""}, {"", ""}, {"-"}} {
+		m := textMap("Bob")
+		m.NPCs[0].Dialogues = d
+		got := legacyRoundTrip(t, m).NPCs[0].Dialogues
+		if !reflect.DeepEqual(got, d) {
+			t.Errorf("dialogues %q came back as %q", d, got)
+		}
+	}
+}
+
+func TestSplitFields(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{"a, b, c", []string{"a", " b", " c"}},
+		{`a, "b, c", d`, []string{"a", ` "b, c"`, " d"}},
+		{`a, "b \" , c", d`, []string{"a", ` "b \" , c"`, " d"}},
+		// a quote inside a field doesn't open one (old files)
+		{`a, say "x, y"`, []string{"a", ` say "x`, ` y"`}},
+		{`"unterminated, x`, []string{`"unterminated, x`}},
+		{"", []string{""}},
+	}
+	for _, tt := range tests {
+		if got := splitFields(tt.line, ','); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitFields(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package mapio
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+)
+
+// Format identifies a map file encoding.
+type Format int
+
+const (
+	// FormatLegacy is the original comma separated text format with
+	// ---SECTION--- blocks. Still readable & writable, but it has no version
+	// and can't be extended without breaking old readers.
+	FormatLegacy Format = iota
+	// FormatJSON is the versioned JSON format.
+	FormatJSON
+	// FormatTMX and FormatTMJ are Tiled's XML and JSON maps, import only
+	// (see tiled.go).
+	FormatTMX
+	FormatTMJ
+	// FormatChunked is a directory with a JSON header and one file per
+	// chunk, for maps too big to load at once (see chunks.go).
+	FormatChunked
+)
+
+func (f Format) String() string {
+	switch f {
+	case FormatJSON:
+		return "json"
+	case FormatTMX:
+		return "tmx"
+	case FormatTMJ:
+		return "tmj"
+	case FormatChunked:
+		return "chunked"
+	default:
+		return "legacy"
+	}
+}
+
+const (
+	// JSONFormatName is stored in every JSON map so other JSON files aren't
+	// mistaken for maps.
+	JSONFormatName = "rpg-map"
+	// JSONVersion is the version written by EncodeJSON. Bump it when the
+	// layout changes and add a step to jsonMigrations.
+	JSONVersion = 3
+)
+
+// jsonFile is the on-disk layout: a header followed by the map fields.
+type jsonFile struct {
+	Format  string `json:"format"`
+	Version int    `json:"version"`
+	// only set in the header of a chunked map, see chunks.go
+	ChunkSize int `json:"chunk_size,omitempty"`
+	MapData
+}
+
+// jsonMigrations upgrade a decoded document one version at a time:
+// jsonMigrations[v] turns version v into v+1. The document is the generic
+// JSON object so migrations can rename or restructure fields freely.
+var jsonMigrations = map[int]func(doc map[string]any) error{
+	// 2 added the named tile layers next to the ground tiles
+	1: func(doc map[string]any) error {
+		if _, ok := doc["layers"]; !ok {
+			doc["layers"] = []any{}
+		}
+		return nil
+	},
+	// 3 added portals & spawn points
+	2: func(doc map[string]any) error {
+		for _, key := range []string{"portals", "spawn_points"} {
+			if _, ok := doc[key]; !ok {
+				doc[key] = []any{}
+			}
+		}
+		return nil
+	},
+}
+
+// DetectFormat guesses the encoding of a map file from its content.
+func DetectFormat(data []byte) Format {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")) // UTF-8 BOM
+	data = bytes.TrimLeft(data, " \t\r\n")
+	if len(data) > 0 && data[0] == '<' {
+		return FormatTMX
+	}
+	if len(data) > 0 && data[0] == '{' {
+		if isTMJ(data) {
+			return FormatTMJ
+		}
+		return FormatJSON
+	}
+	return FormatLegacy
+}
+
+// DecodeJSON parses a JSON map, migrating older versions to the current one.
+func DecodeJSON(data []byte) (*MapData, error) {
+	var header struct {
+		Format  string `json:"format"`
+		Version int    `json:"version"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, fmt.Errorf("invalid JSON map: %v", err)
+	}
+	if header.Format != JSONFormatName {
+		return nil, fmt.Errorf("not a map file (format %q, expected %q)", header.Format, JSONFormatName)
+	}
+	if header.Version < 1 {
+		return nil, fmt.Errorf("invalid map version %d", header.Version)
+	}
+	if header.Version > JSONVersion {
+		return nil, fmt.Errorf("map version %d is newer than supported version %d", header.Version, JSONVersion)
+	}
+
+	if header.Version < JSONVersion {
+		var doc map[string]any
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("invalid JSON map: %v", err)
+		}
+		for v := header.Version; v < JSONVersion; v++ {
+			migrate, ok := jsonMigrations[v]
+			if !ok {
+				return nil, fmt.Errorf("no migration from map version %d", v)
+			}
+			if err := migrate(doc); err != nil {
+				return nil, fmt.Errorf("migrating map from version %d: %v", v, err)
+			}
+		}
+		doc["version"] = JSONVersion
+		var err error
+		if data, err = json.Marshal(doc); err != nil {
+			return nil, err
+		}
+	}
+
+	var f jsonFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("invalid JSON map: %v", err)
+	}
+	m := f.MapData
+	m.fillEmpty()
+	return &m, nil
+}
+
+// collapses arrays of plain numbers (tile rows) onto a single line
+var numberArray = regexp.MustCompile(`\[[\s\d,.\-]*\]`)
+
+// EncodeJSON writes map data in the current JSON version.
+func EncodeJSON(w io.Writer, m *MapData) error {
+	return encodeJSON(w, jsonFile{Format: JSONFormatName, Version: JSONVersion, MapData: *m})
+}
+
+func encodeJSON(w io.Writer, f jsonFile) error {
+	f.fillEmpty()
+	data, err := marshalTiles(&f)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// marshalTiles is json.MarshalIndent with one tile row per line instead of
+// one tile per line.
+func marshalTiles(v any) ([]byte, error) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	data = numberArray.ReplaceAllFunc(data, func(b []byte) []byte {
+		var out bytes.Buffer
+		if err := json.Compact(&out, b); err != nil {
+			return b
+		}
+		return out.Bytes()
+	})
+	return append(data, '\n'), nil
+}
+
+// fillEmpty replaces nil lists with empty ones, so JSON has [] instead of
+// null and loaded maps look the same whichever format they came from.
+func (m *MapData) fillEmpty() {
+	if m.Tiles == nil {
+		m.Tiles = [][]int{}
+	}
+	if m.Layers == nil {
+		m.Layers = []TileLayer{}
+	}
+	if m.Nodes == nil {
+		m.Nodes = []Node{}
+	}
+	if m.Paths == nil {
+		m.Paths = []Path{}
+	}
+	if m.Sprites == nil {
+		m.Sprites = []Sprite{}
+	}
+	if m.NPCs == nil {
+		m.NPCs = []NPC{}
+	}
+	if m.Spawners == nil {
+		m.Spawners = []EnemySpawner{}
+	}
+	if m.Portals == nil {
+		m.Portals = []Portal{}
+	}
+	if m.SpawnPoints == nil {
+		m.SpawnPoints = []SpawnPoint{}
+	}
+	for i := range m.NPCs {
+		if m.NPCs[i].Dialogues == nil {
+			m.NPCs[i].Dialogues = []string{}
+		}
+	}
+}
+
+// ConvertFile loads a map in any format and saves it to dst, in the format
+// given by dst's extension. This is the migration path from legacy maps:
+// ConvertFile("map.txt", "map.json").
+func ConvertFile(src, dst string) error {
+	m, err := LoadMapFromFile(src)
+	if err != nil {
+		return err
+	}
+	return SaveMapToFile(m, dst)
+}
@@ -0,0 +1,127 @@
+package mapio
+
+// Layer names. The ground layer is MapData.Tiles (terrain ids, see
+// import/tiles.json); every other layer lives in MapData.Layers and is
+// created on first write.
+const (
+	LayerGround = "ground"
+	// decoration drawn over the ground (flowers, paths...), 0 = empty
+	LayerDetail = "detail"
+	// drawn above the player (roofs, tree tops), 0 = empty
+	LayerOverlay = "overlay"
+	// collision override, see Collision* values
+	LayerCollision = "collision"
+)
+
+// LayerNames lists the layers in drawing order.
+var LayerNames = []string{LayerGround, LayerDetail, LayerOverlay, LayerCollision}
+
+// Collision layer values.
+const (
+	CollisionDefault  = 0 // decided by the ground tile (mountains block)
+	CollisionBlocked  = 1
+	CollisionWalkable = 2
+)
+
+// TileLayer is a named grid of tile values with the same size as the map.
+type TileLayer struct {
+	Name  string  `json:"name"`
+	Tiles [][]int `json:"tiles"`
+}
+
+// IsLayerName reports whether name is a known layer.
+func IsLayerName(name string) bool {
+	for _, n := range LayerNames {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// LayerTiles returns the grid of a layer, nil if the layer has no data yet.
+func (m *MapData) LayerTiles(name string) [][]int {
+	if name == LayerGround {
+		return m.Tiles
+	}
+	for i := range m.Layers {
+		if m.Layers[i].Name == name {
+			return m.Layers[i].Tiles
+		}
+	}
+	return nil
+}
+
+// GetLayerTile safely gets a tile of a layer, 0 when out of bounds or the
+// layer is empty.
+func (m *MapData) GetLayerTile(name string, x, y int) int {
+	tiles := m.LayerTiles(name)
+	if y < 0 || y >= len(tiles) || x < 0 || x >= len(tiles[y]) {
+		return 0
+	}
+	return tiles[y][x]
+}
+
+// SetLayerTile safely sets a tile of a layer, creating the layer if needed.
+func (m *MapData) SetLayerTile(name string, x, y, value int) {
+	if x < 0 || x >= m.Width || y < 0 || y >= m.Height {
+		return
+	}
+	tiles := m.LayerTiles(name)
+	if tiles == nil {
+		if value == 0 || !IsLayerName(name) {
+			return // nothing to store
+		}
+		tiles = newGrid(m.Width, m.Height)
+		m.Layers = append(m.Layers, TileLayer{Name: name, Tiles: tiles})
+	}
+	if y >= len(tiles) || x >= len(tiles[y]) {
+		return
+	}
+	tiles[y][x] = value
+}
+
+// Resize changes the map size keeping the top left corner. New ground tiles
+// are set to fill, new tiles of the other layers to 0. Things placed outside
+// the new size are kept, Validate reports them.
+func (m *MapData) Resize(width, height, fill int) {
+	resize := func(grid [][]int, fill int) [][]int {
+		out := make([][]int, height)
+		for y := range out {
+			out[y] = make([]int, width)
+			for x := range out[y] {
+				if y < len(grid) && x < len(grid[y]) {
+					out[y][x] = grid[y][x]
+				} else {
+					out[y][x] = fill
+				}
+			}
+		}
+		return out
+	}
+	m.Tiles = resize(m.Tiles, fill)
+	for i := range m.Layers {
+		m.Layers[i].Tiles = resize(m.Layers[i].Tiles, 0)
+	}
+	m.Width, m.Height = width, height
+}
+
+func newGrid(width, height int) [][]int {
+	g := make([][]int, height)
+	for y := range g {
+		g[y] = make([]int, width)
+	}
+	return g
+}
+
+// layerIsEmpty reports whether every tile is 0.
+func layerIsEmpty(tiles [][]int) bool {
+	for _, row := range tiles {
+		for _, v := range row {
+			if v != 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
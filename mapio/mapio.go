@@ -2,64 +2,74 @@ package mapio
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 )
 
 // Position struct for map data
 type Pos struct {
-	X, Y float32
+	X float32 `json:"x"`
+	Y float32 `json:"y"`
 }
 
 // Node represents a pathfinding node
 type Node struct {
-	ID  int
-	Pos Pos
+	ID  int `json:"id"`
+	Pos Pos `json:"pos"`
 }
 
 // Path represents a connection between two nodes
 type Path struct {
-	NodeAID int
-	NodeBID int
-	Cost    float32
+	NodeAID int     `json:"node_a"`
+	NodeBID int     `json:"node_b"`
+	Cost    float32 `json:"cost"`
 }
 
 // Sprite represents an object placed on the map
 type Sprite struct {
-	Type int
-	Pos  Pos
+	Type int `json:"type"`
+	Pos  Pos `json:"pos"`
 }
 
 // MapData contains all map information
 type MapData struct {
-	Width    int
-	Height   int
-	Tiles    [][]int
-	Nodes    []Node
-	Paths    []Path
-	Sprites  []Sprite
-	NPCs     []NPC
-	Spawners []EnemySpawner
+	Width  int `json:"width"`
+	Height int `json:"height"`
+	// ground layer
+	Tiles [][]int `json:"tiles"`
+	// the other layers (detail, overlay, collision), see layers.go
+	Layers   []TileLayer    `json:"layers"`
+	Nodes    []Node         `json:"nodes"`
+	Paths    []Path         `json:"paths"`
+	Sprites  []Sprite       `json:"sprites"`
+	NPCs     []NPC          `json:"npcs"`
+	Spawners []EnemySpawner `json:"spawners"`
+	// map exits & arrival points, see portals.go
+	Portals     []Portal     `json:"portals"`
+	SpawnPoints []SpawnPoint `json:"spawn_points"`
 }
 
 // NPC represents a placed NPC with dialogue. VoiceKey reserved for future voice integration.
 type NPC struct {
-	Name       string
-	Pos        Pos
-	Dialogues  []string
-	VoiceKey   string // placeholder for future audio key/asset id
-	SpritePath string
+	Name       string   `json:"name"`
+	Pos        Pos      `json:"pos"`
+	Dialogues  []string `json:"dialogues"`
+	VoiceKey   string   `json:"voice_key,omitempty"` // placeholder for future audio key/asset id
+	SpritePath string   `json:"sprite_path,omitempty"`
 }
 
 // EnemySpawner defines an enemy spawn point with simple parameters.
 // IntervalSeconds: respawn check interval; MaxAlive: desired alive enemies maintained.
 type EnemySpawner struct {
-	Pos             Pos
-	Radius          float32
-	MaxAlive        int
-	IntervalSeconds float32
+	Pos             Pos     `json:"pos"`
+	Radius          float32 `json:"radius"`
+	MaxAlive        int     `json:"max_alive"`
+	IntervalSeconds float32 `json:"interval_seconds"`
 }
 
 // NewMapData creates a new empty map with specified dimensions
@@ -73,35 +83,127 @@ func NewMapData(width, height int) *MapData {
 		Width:   width,
 		Height:  height,
 		Tiles:   tiles,
+		Layers:  []TileLayer{},
 		Nodes:   []Node{},
 		Paths:   []Path{},
 		Sprites: []Sprite{},
 		NPCs:    []NPC{},
+
+		Portals:     []Portal{},
+		SpawnPoints: []SpawnPoint{},
 	}
 }
 
-// LoadMapFromFile reads map data from a text file
+// LoadMapFromFile reads map data from a file, detecting the format (JSON or
+// the legacy text format) from its content. A chunked map directory is
+// loaded whole.
 func LoadMapFromFile(filename string) (*MapData, error) {
-	file, err := os.Open(filename)
+	var mapData *MapData
+	if IsChunkedMap(filename) {
+		s, err := OpenChunkStore(filename)
+		if err != nil {
+			return nil, err
+		}
+		if mapData, err = s.LoadAll(); err != nil {
+			return nil, err
+		}
+	} else {
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			return nil, fmt.Errorf("error opening file: %v", err)
+		}
+		if mapData, err = Decode(data); err != nil {
+			return nil, err
+		}
+	}
+
+	fmt.Printf("Loaded map: %dx%d with %d nodes, %d paths, %d sprites, %d NPCs\n",
+		mapData.Width, mapData.Height, len(mapData.Nodes), len(mapData.Paths), len(mapData.Sprites), len(mapData.NPCs))
+	for _, d := range Validate(mapData) {
+		fmt.Printf("%s: %s\n", filename, d)
+	}
+
+	return mapData, nil
+}
+
+// Decode parses map data in any supported format.
+func Decode(data []byte) (*MapData, error) {
+	switch DetectFormat(data) {
+	case FormatJSON:
+		return DecodeJSON(data)
+	case FormatTMX:
+		return DecodeTMX(data)
+	case FormatTMJ:
+		return DecodeTMJ(data)
+	default:
+		return DecodeLegacy(bytes.NewReader(data))
+	}
+}
+
+// SaveMapToFile writes map data to a file. Files ending in .json get the
+// versioned JSON format, .chunks a chunked map directory, anything else the
+// legacy text format. Tiled maps can only be imported.
+func SaveMapToFile(mapData *MapData, filename string) error {
+	switch f := FormatForPath(filename); f {
+	case FormatTMX, FormatTMJ:
+		return fmt.Errorf("can't write %s maps, save as .json or .txt", f)
+	case FormatChunked:
+		return SaveChunked(mapData, filename, DefaultChunkSize)
+	}
+	file, err := os.Create(filename)
 	if err != nil {
-		return nil, fmt.Errorf("error opening file: %v", err)
+		return fmt.Errorf("error creating file: %v", err)
 	}
 	defer file.Close()
 
+	if FormatForPath(filename) == FormatJSON {
+		return EncodeJSON(file, mapData)
+	}
+	return EncodeLegacy(file, mapData)
+}
+
+// FormatForPath picks the format to save to from the file extension.
+func FormatForPath(filename string) Format {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".json":
+		return FormatJSON
+	case ".tmx":
+		return FormatTMX
+	case ".tmj":
+		return FormatTMJ
+	case ".chunks":
+		return FormatChunked
+	}
+	return FormatLegacy
+}
+
+// DecodeLegacy reads the legacy comma separated text format (tile rows
+// followed by ---SECTION--- blocks).
+func DecodeLegacy(r io.Reader) (*MapData, error) {
 	mapData := &MapData{
+		Layers:   []TileLayer{},
 		Nodes:    []Node{},
 		Paths:    []Path{},
 		Sprites:  []Sprite{},
 		NPCs:     []NPC{},
 		Spawners: []EnemySpawner{},
+
+		Portals:     []Portal{},
+		SpawnPoints: []SpawnPoint{},
 	}
 
-	scanner := bufio.NewScanner(file)
+	scanner := bufio.NewScanner(r)
+	// tile rows of big maps don't fit the default 64k token size
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
 	isReadingSprites := false
 	isReadingNodes := false
 	isReadingPaths := false
 	isReadingNPCs := false
 	isReadingSpawners := false
+	isReadingPortals := false
+	isReadingSpawnPoints := false
+	// tile rows of a ---LAYER name--- section go here instead of the ground
+	var layer *TileLayer
 
 	y := 0
 	var maxWidth int
@@ -112,6 +214,26 @@ func LoadMapFromFile(filename string) (*MapData, error) {
 			continue
 		}
 
+		// Extra tile layers: ---LAYER name--- followed by rows like the ground
+		if strings.HasPrefix(line, "---LAYER ") && strings.HasSuffix(line, "---") {
+			name := strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(line, "---LAYER "), "---"))
+			mapData.Layers = append(mapData.Layers, TileLayer{Name: name, Tiles: [][]int{}})
+			layer = &mapData.Layers[len(mapData.Layers)-1]
+			isReadingSprites = false
+			isReadingNodes = false
+			isReadingPaths = false
+			isReadingNPCs = false
+			isReadingSpawners = false
+			isReadingPortals = false
+			isReadingSpawnPoints = false
+			continue
+		}
+		if strings.HasPrefix(line, "---") {
+			layer = nil
+			isReadingPortals = false
+			isReadingSpawnPoints = false
+		}
+
 		// Look for section headers
 		switch line {
 		case "---SPRITES---":
@@ -146,6 +268,15 @@ func LoadMapFromFile(filename string) (*MapData, error) {
 			isReadingNPCs = false
 			isReadingSpawners = true
 			continue
+		case "---PORTALS---", "---SPAWNPOINTS---":
+			isReadingSprites = false
+			isReadingNodes = false
+			isReadingPaths = false
+			isReadingNPCs = false
+			isReadingSpawners = false
+			isReadingPortals = line == "---PORTALS---"
+			isReadingSpawnPoints = line == "---SPAWNPOINTS---"
+			continue
 		}
 
 		// Process data based on current section
@@ -166,7 +297,7 @@ func LoadMapFromFile(filename string) (*MapData, error) {
 		} else if isReadingPaths {
 			path, err := parsePathLine(line)
 			if err != nil {
-				// Paths might have different formats, so we're more lenient
+				fmt.Printf("Warning: Invalid path data: %s\n", line)
 				continue
 			}
 			mapData.Paths = append(mapData.Paths, *path)
@@ -186,6 +317,28 @@ func LoadMapFromFile(filename string) (*MapData, error) {
 			}
 			mapData.Spawners = append(mapData.Spawners, *sp)
 
+		} else if isReadingPortals {
+			p, err := parsePortalLine(line)
+			if err != nil {
+				fmt.Printf("Warning: Invalid PORTAL data: %s\n", line)
+				continue
+			}
+			mapData.Portals = append(mapData.Portals, *p)
+
+		} else if isReadingSpawnPoints {
+			sp, err := parseSpawnPointLine(line)
+			if err != nil {
+				fmt.Printf("Warning: Invalid SPAWN data: %s\n", line)
+				continue
+			}
+			mapData.SpawnPoints = append(mapData.SpawnPoints, *sp)
+
+		} else if layer != nil {
+			row, err := parseTileRow(line)
+			if err != nil {
+				return nil, fmt.Errorf("error parsing %s layer row %d: %v", layer.Name, len(layer.Tiles), err)
+			}
+			layer.Tiles = append(layer.Tiles, row)
 		} else {
 			// Process map tile data
 			if mapData.Tiles == nil {
@@ -193,20 +346,9 @@ func LoadMapFromFile(filename string) (*MapData, error) {
 				mapData.Tiles = [][]int{}
 			}
 
-			values := strings.Split(line, ",")
-			row := make([]int, len(values))
-
-			for x, value := range values {
-				value = strings.TrimSpace(value)
-				if value == "" {
-					continue
-				}
-
-				intValue, err := strconv.Atoi(value)
-				if err != nil {
-					return nil, fmt.Errorf("error parsing map value at (%d,%d): %v", x, y, err)
-				}
-				row[x] = intValue
+			row, err := parseTileRow(line)
+			if err != nil {
+				return nil, fmt.Errorf("error parsing map row %d: %v", y, err)
 			}
 
 			mapData.Tiles = append(mapData.Tiles, row)
@@ -224,22 +366,12 @@ func LoadMapFromFile(filename string) (*MapData, error) {
 	mapData.Width = maxWidth
 	mapData.Height = y
 
-	fmt.Printf("Loaded map: %dx%d with %d nodes, %d paths, %d sprites, %d NPCs\n",
-		mapData.Width, mapData.Height, len(mapData.Nodes), len(mapData.Paths), len(mapData.Sprites), len(mapData.NPCs))
-
 	return mapData, nil
 }
 
-// SaveMapToFile writes map data to a text file
-func SaveMapToFile(mapData *MapData, filename string) error {
-	file, err := os.Create(filename)
-	if err != nil {
-		return fmt.Errorf("error creating file: %v", err)
-	}
-	defer file.Close()
-
-	writer := bufio.NewWriter(file)
-	defer writer.Flush()
+// EncodeLegacy writes map data in the legacy text format.
+func EncodeLegacy(w io.Writer, mapData *MapData) error {
+	writer := bufio.NewWriter(w)
 
 	// Write map tiles
 	for y := 0; y < mapData.Height; y++ {
@@ -254,6 +386,25 @@ func SaveMapToFile(mapData *MapData, filename string) error {
 		writer.WriteString(strings.Join(row, ", ") + "\n")
 	}
 
+	// Write the other tile layers, skipping empty ones
+	for _, l := range mapData.Layers {
+		if layerIsEmpty(l.Tiles) {
+			continue
+		}
+		writer.WriteString("---LAYER " + l.Name + "---\n")
+		for y := 0; y < mapData.Height; y++ {
+			row := make([]string, mapData.Width)
+			for x := 0; x < mapData.Width; x++ {
+				if y < len(l.Tiles) && x < len(l.Tiles[y]) {
+					row[x] = strconv.Itoa(l.Tiles[y][x])
+				} else {
+					row[x] = "0"
+				}
+			}
+			writer.WriteString(strings.Join(row, ", ") + "\n")
+		}
+	}
+
 	// Write sprites section
 	if len(mapData.Sprites) > 0 {
 		writer.WriteString("---SPRITES---\n")
@@ -262,20 +413,13 @@ func SaveMapToFile(mapData *MapData, filename string) error {
 		}
 	}
 
-	// Write NPCs section (format: NPC, Name, X, Y, VoiceKeyPlaceholder, dialogue1|dialogue2|...)
+	// Write NPCs section (format: NPC, Name, X, Y, VoiceKey, SpritePath, dialogue1|dialogue2|...),
+	// text quoted where needed, see escape.go
 	if len(mapData.NPCs) > 0 {
 		writer.WriteString("---NPCS---\n")
 		for _, n := range mapData.NPCs {
-			voice := n.VoiceKey
-			if voice == "" {
-				voice = "-"
-			}
-			joined := strings.Join(n.Dialogues, "|")
-			path := n.SpritePath
-			if path == "" {
-				path = "-"
-			}
-			writer.WriteString(fmt.Sprintf("NPC, %s, %.1f, %.1f, %s, %s, %s\n", n.Name, n.Pos.X, n.Pos.Y, voice, path, joined))
+			writer.WriteString(fmt.Sprintf("NPC, %s, %.1f, %.1f, %s, %s, %s\n",
+				textField(n.Name), n.Pos.X, n.Pos.Y, textField(n.VoiceKey), textField(n.SpritePath), joinDialogues(n.Dialogues)))
 		}
 	}
 
@@ -287,6 +431,23 @@ func SaveMapToFile(mapData *MapData, filename string) error {
 		}
 	}
 
+	// Write portals section (format: PORTAL, Name, X, Y, W, H, TargetMap, TargetSpawn)
+	if len(mapData.Portals) > 0 {
+		writer.WriteString("---PORTALS---\n")
+		for _, p := range mapData.Portals {
+			writer.WriteString(fmt.Sprintf("PORTAL, %s, %.1f, %.1f, %.1f, %.1f, %s, %s\n",
+				textField(p.Name), p.Pos.X, p.Pos.Y, p.Width, p.Height, textField(p.TargetMap), textField(p.TargetSpawn)))
+		}
+	}
+
+	// Write spawn points section (format: SPAWN, Name, X, Y)
+	if len(mapData.SpawnPoints) > 0 {
+		writer.WriteString("---SPAWNPOINTS---\n")
+		for _, sp := range mapData.SpawnPoints {
+			writer.WriteString(fmt.Sprintf("SPAWN, %s, %.1f, %.1f\n", textField(sp.Name), sp.Pos.X, sp.Pos.Y))
+		}
+	}
+
 	// Write nodes section
 	if len(mapData.Nodes) > 0 {
 		writer.WriteString("---NODES---\n")
@@ -303,11 +464,29 @@ func SaveMapToFile(mapData *MapData, filename string) error {
 		}
 	}
 
-	return nil
+	return writer.Flush()
 }
 
 // Helper functions for parsing lines
 
+// parseTileRow parses one comma separated row of tile values.
+func parseTileRow(line string) ([]int, error) {
+	values := strings.Split(line, ",")
+	row := make([]int, len(values))
+	for x, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		intValue, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("column %d: %v", x, err)
+		}
+		row[x] = intValue
+	}
+	return row, nil
+}
+
 func parseSpriteLine(line string) (*Sprite, error) {
 	values := strings.Split(line, ",")
 	if len(values) != 3 {
@@ -399,17 +578,16 @@ func parsePathLine(line string) (*Path, error) {
 }
 
 // parseNPCLine parses an NPC line of format:
-// NPC, Name, X, Y, VoiceKey, dialogue1|dialogue2|...
-// VoiceKey may be '-' placeholder.
+// NPC, Name, X, Y, VoiceKey, SpritePath, dialogue1|dialogue2|...
+// Text fields may be quoted (see escape.go), '-' is empty.
 func parseNPCLine(line string) (*NPC, error) {
-	values := strings.Split(line, ",")
+	values := splitFields(line, ',')
 	if len(values) < 7 {
 		return nil, fmt.Errorf("invalid NPC format")
 	}
 	if strings.TrimSpace(values[0]) != "NPC" {
 		return nil, fmt.Errorf("not an NPC line")
 	}
-	name := strings.TrimSpace(values[1])
 	x, err := strconv.ParseFloat(strings.TrimSpace(values[2]), 32)
 	if err != nil {
 		return nil, fmt.Errorf("invalid NPC X")
@@ -418,24 +596,15 @@ func parseNPCLine(line string) (*NPC, error) {
 	if err != nil {
 		return nil, fmt.Errorf("invalid NPC Y")
 	}
-	voiceKey := strings.TrimSpace(values[4])
-	if voiceKey == "-" {
-		voiceKey = ""
-	}
-	spritePath := strings.TrimSpace(values[5])
-	if spritePath == "-" {
-		spritePath = ""
-	}
-	dialogueField := strings.Join(values[6:], ",")
-	dialogueField = strings.TrimSpace(dialogueField)
-	dialogues := []string{}
-	if dialogueField != "" {
-		dialogues = strings.Split(dialogueField, "|")
-	}
-	for i, d := range dialogues {
-		dialogues[i] = strings.TrimSpace(d)
-	}
-	return &NPC{Name: name, Pos: Pos{X: float32(x), Y: float32(y)}, Dialogues: dialogues, VoiceKey: voiceKey, SpritePath: spritePath}, nil
+	// unquoted commas in the dialogue (older files) belong to it
+	dialogues := splitDialogues(strings.Join(values[6:], ","))
+	return &NPC{
+		Name:       parseTextField(values[1]),
+		Pos:        Pos{X: float32(x), Y: float32(y)},
+		Dialogues:  dialogues,
+		VoiceKey:   parseTextField(values[4]),
+		SpritePath: parseTextField(values[5]),
+	}, nil
 }
 
 // parseSpawnerLine parses: SPAWNER, X, Y, Radius, MaxAlive, IntervalSeconds
@@ -470,6 +639,54 @@ func parseSpawnerLine(line string) (*EnemySpawner, error) {
 	return &EnemySpawner{Pos: Pos{X: float32(xf), Y: float32(yf)}, Radius: float32(rf), MaxAlive: maxAlive, IntervalSeconds: float32(interval)}, nil
 }
 
+// parsePortalLine parses: PORTAL, Name, X, Y, W, H, TargetMap, TargetSpawn
+// ('-' for empty names).
+func parsePortalLine(line string) (*Portal, error) {
+	values := splitFields(line, ',')
+	if len(values) != 8 {
+		return nil, fmt.Errorf("invalid portal format")
+	}
+	if strings.TrimSpace(values[0]) != "PORTAL" {
+		return nil, fmt.Errorf("not a portal line")
+	}
+	var nums [4]float32
+	for i := range nums {
+		f, err := strconv.ParseFloat(strings.TrimSpace(values[2+i]), 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid portal number %q", values[2+i])
+		}
+		nums[i] = float32(f)
+	}
+	return &Portal{
+		Name:        parseTextField(values[1]),
+		Pos:         Pos{X: nums[0], Y: nums[1]},
+		Width:       nums[2],
+		Height:      nums[3],
+		TargetMap:   parseTextField(values[6]),
+		TargetSpawn: parseTextField(values[7]),
+	}, nil
+}
+
+// parseSpawnPointLine parses: SPAWN, Name, X, Y
+func parseSpawnPointLine(line string) (*SpawnPoint, error) {
+	values := splitFields(line, ',')
+	if len(values) != 4 {
+		return nil, fmt.Errorf("invalid spawn point format")
+	}
+	if strings.TrimSpace(values[0]) != "SPAWN" {
+		return nil, fmt.Errorf("not a spawn point line")
+	}
+	x, err := strconv.ParseFloat(strings.TrimSpace(values[2]), 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid spawn point X")
+	}
+	y, err := strconv.ParseFloat(strings.TrimSpace(values[3]), 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid spawn point Y")
+	}
+	return &SpawnPoint{Name: parseTextField(values[1]), Pos: Pos{X: float32(x), Y: float32(y)}}, nil
+}
+
 // GetTile safely gets a tile value at the specified coordinates
 func (m *MapData) GetTile(x, y int) int {
 	if y < 0 || y >= len(m.Tiles) || x < 0 || x >= len(m.Tiles[y]) {
@@ -514,13 +731,70 @@ func (m *MapData) RemoveNode(id int) {
 	m.Paths = filteredPaths
 }
 
-// AddPath adds a path between two nodes
-func (m *MapData) AddPath(nodeAID, nodeBID int, cost float32) {
+// AddPath connects two nodes, the cost is the distance between them. It
+// returns false (and adds nothing) if a node doesn't exist, both are the
+// same node or they are already connected.
+func (m *MapData) AddPath(nodeAID, nodeBID int) bool {
+	a, b := m.FindNodeByID(nodeAID), m.FindNodeByID(nodeBID)
+	if a == nil || b == nil {
+		return false
+	}
+	return m.AddPathWithCost(nodeAID, nodeBID, distance(a.Pos, b.Pos))
+}
+
+// AddPathWithCost is AddPath with a given cost.
+func (m *MapData) AddPathWithCost(nodeAID, nodeBID int, cost float32) bool {
+	if nodeAID == nodeBID || m.FindNodeByID(nodeAID) == nil || m.FindNodeByID(nodeBID) == nil {
+		return false
+	}
+	if m.FindPath(nodeAID, nodeBID) >= 0 {
+		return false
+	}
 	m.Paths = append(m.Paths, Path{
 		NodeAID: nodeAID,
 		NodeBID: nodeBID,
 		Cost:    cost,
 	})
+	return true
+}
+
+// FindPath returns the index of the path between two nodes (either
+// direction), -1 if they aren't connected.
+func (m *MapData) FindPath(nodeAID, nodeBID int) int {
+	for i, p := range m.Paths {
+		if (p.NodeAID == nodeAID && p.NodeBID == nodeBID) || (p.NodeAID == nodeBID && p.NodeBID == nodeAID) {
+			return i
+		}
+	}
+	return -1
+}
+
+// RemovePath removes the path between two nodes (either direction),
+// reporting whether there was one.
+func (m *MapData) RemovePath(nodeAID, nodeBID int) bool {
+	removed := false
+	filtered := m.Paths[:0]
+	for _, p := range m.Paths {
+		if (p.NodeAID == nodeAID && p.NodeBID == nodeBID) || (p.NodeAID == nodeBID && p.NodeBID == nodeAID) {
+			removed = true
+			continue
+		}
+		filtered = append(filtered, p)
+	}
+	m.Paths = filtered
+	return removed
+}
+
+// FindNodeAt returns the node closest to pos within radius, nil if none.
+func (m *MapData) FindNodeAt(pos Pos, radius float32) *Node {
+	var best *Node
+	bestDist := radius
+	for i := range m.Nodes {
+		if d := distance(m.Nodes[i].Pos, pos); d <= bestDist {
+			best, bestDist = &m.Nodes[i], d
+		}
+	}
+	return best
 }
 
 // FindNodeByID finds a node by its ID
@@ -0,0 +1,122 @@
+package mapio
+
+import (
+	"math"
+	"testing"
+)
+
+func pathMap() *MapData {
+	m := NewMapData(10, 10)
+	m.AddNode(1, 0, 0)
+	m.AddNode(2, 30, 40)
+	m.AddNode(3, 100, 0)
+	return m
+}
+
+func TestAddPathCost(t *testing.T) {
+	m := pathMap()
+	if !m.AddPath(1, 2) {
+		t.Fatal("AddPath(1, 2) failed")
+	}
+	if got := m.Paths[0].Cost; got != 50 {
+		t.Errorf("cost = %v, want the distance 50", got)
+	}
+	if !m.AddPath(3, 2) {
+		t.Fatal("AddPath(3, 2) failed")
+	}
+	want := float32(math.Hypot(70, 40))
+	if got := m.Paths[1].Cost; math.Abs(float64(got-want)) > 1e-4 {
+		t.Errorf("cost = %v, want %v", got, want)
+	}
+	if !m.AddPathWithCost(1, 3, 7) || m.Paths[2].Cost != 7 {
+		t.Errorf("AddPathWithCost didn't keep the cost: %+v", m.Paths)
+	}
+}
+
+func TestAddPathRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b int
+	}{
+		{"self", 1, 1},
+		{"duplicate", 1, 2},
+		{"duplicate reversed", 2, 1},
+		{"missing a", 9, 1},
+		{"missing b", 1, 9},
+	}
+	for _, tt := range tests {
+		m := pathMap()
+		m.AddPath(1, 2)
+		if m.AddPath(tt.a, tt.b) {
+			t.Errorf("%s: AddPath(%d, %d) accepted", tt.name, tt.a, tt.b)
+		}
+		if m.AddPathWithCost(tt.a, tt.b, 1) {
+			t.Errorf("%s: AddPathWithCost(%d, %d) accepted", tt.name, tt.a, tt.b)
+		}
+		if len(m.Paths) != 1 {
+			t.Errorf("%s: %d paths, want 1", tt.name, len(m.Paths))
+		}
+	}
+}
+
+func TestFindAndRemovePath(t *testing.T) {
+	for _, rev := range []bool{false, true} {
+		m := pathMap()
+		m.AddPath(1, 2)
+		m.AddPath(2, 3)
+		a, b := 1, 2
+		if rev {
+			a, b = b, a
+		}
+		if i := m.FindPath(a, b); i != 0 {
+			t.Errorf("FindPath(%d, %d) = %d, want 0", a, b, i)
+		}
+		if !m.RemovePath(a, b) {
+			t.Errorf("RemovePath(%d, %d) = false", a, b)
+		}
+		if m.RemovePath(a, b) {
+			t.Errorf("RemovePath(%d, %d) removed twice", a, b)
+		}
+		if m.FindPath(1, 2) != -1 || len(m.Paths) != 1 || m.FindPath(3, 2) != 0 {
+			t.Errorf("after removing %d-%d: %+v", a, b, m.Paths)
+		}
+	}
+	if m := pathMap(); m.FindPath(1, 3) != -1 || m.RemovePath(1, 3) {
+		t.Error("found a path that was never added")
+	}
+}
+
+func TestFindNodeAt(t *testing.T) {
+	m := pathMap()
+	m.AddNode(4, 10, 0)
+	tests := []struct {
+		pos    Pos
+		radius float32
+		want   int // node id, 0 = none
+	}{
+		{Pos{X: 0, Y: 0}, 16, 1},
+		{Pos{X: 4, Y: 0}, 16, 1},
+		{Pos{X: 6, Y: 0}, 16, 4}, // both in range, 4 is closer
+		{Pos{X: 26, Y: 0}, 16, 4},
+		{Pos{X: 27, Y: 0}, 16, 0},  // just past the radius
+		{Pos{X: 30, Y: 56}, 16, 2}, // exactly on the radius
+		{Pos{X: 30, Y: 57}, 16, 0},
+		{Pos{X: 500, Y: 500}, 16, 0},
+		{Pos{X: 500, Y: 500}, 1000, 3},
+	}
+	for _, tt := range tests {
+		n := m.FindNodeAt(tt.pos, tt.radius)
+		got := 0
+		if n != nil {
+			got = n.ID
+		}
+		if got != tt.want {
+			t.Errorf("FindNodeAt(%v, %v) = node %d, want %d", tt.pos, tt.radius, got, tt.want)
+		}
+	}
+	// the result points into the map
+	m.FindNodeAt(Pos{X: 100, Y: 0}, 1).Pos.X = 99
+	if m.FindNodeByID(3).Pos.X != 99 {
+		t.Error("FindNodeAt returned a copy")
+	}
+}
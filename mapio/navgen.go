@@ -0,0 +1,251 @@
+package mapio
+
+import (
+	"math"
+)
+
+// Nav graph generation
+//
+// GenerateNavGraph places nodes & paths on the walkable tiles so designers
+// don't have to. The map is cut into Spacing x Spacing cells; each cell
+// with walkable tiles gets a node on the tile furthest from any wall (ties
+// go to the cell centre), which keeps nodes in the middle of corridors and
+// rooms. Nodes of neighbouring cells are connected when a body walking
+// straight between them stays on walkable tiles. Diagonal connections are
+// left out where going around over the two other nodes is possible anyway.
+//
+// Node positions are the top left of the node tile, the way the game places
+// bodies, so a body standing on a node covers exactly that tile.
+//
+// Void (ground 0) isn't walkable here: it's the outside of the map, the
+// game just doesn't stop you from walking there.
+
+// DefaultNavSpacing is the cell size (in tiles) used when none is given.
+const DefaultNavSpacing = 4
+
+// NavGenOptions tune GenerateNavGraph.
+type NavGenOptions struct {
+	// tiles between nodes, 0 = DefaultNavSpacing
+	Spacing int
+	// tile definitions for solidity & speed; nil = only mountains block and
+	// every tile has speed 1
+	Tiles *TileSet
+}
+
+// GenerateNavGraph replaces the map's nodes and paths with generated ones.
+// Path costs are the walking distance divided by the speed of the tiles on
+// the way. It returns the number of nodes and paths.
+func (m *MapData) GenerateNavGraph(opts NavGenOptions) (nodes, paths int) {
+	spacing := opts.Spacing
+	if spacing <= 0 {
+		spacing = DefaultNavSpacing
+	}
+	g := navGen{m: m, tiles: opts.Tiles}
+	g.clearance = g.computeClearance()
+
+	cw := (m.Width + spacing - 1) / spacing
+	ch := (m.Height + spacing - 1) / spacing
+	// cell -> node id, -1 without a node
+	cellNode := make([]int, cw*ch)
+	var newNodes []Node
+	for cy := 0; cy < ch; cy++ {
+		for cx := 0; cx < cw; cx++ {
+			cellNode[cy*cw+cx] = -1
+			x, y, ok := g.pickTile(cx*spacing, cy*spacing, spacing)
+			if !ok {
+				continue
+			}
+			id := len(newNodes)
+			newNodes = append(newNodes, Node{ID: id, Pos: Pos{X: float32(x * TileSize), Y: float32(y * TileSize)}})
+			cellNode[cy*cw+cx] = id
+		}
+	}
+
+	nodeAt := func(cx, cy int) int {
+		if cx < 0 || cy < 0 || cx >= cw || cy >= ch {
+			return -1
+		}
+		return cellNode[cy*cw+cx]
+	}
+	linked := map[[2]int]bool{}
+	link := func(a, b int) bool {
+		if a < 0 || b < 0 {
+			return false
+		}
+		if a > b {
+			a, b = b, a
+		}
+		if done, ok := linked[[2]int{a, b}]; ok {
+			return done
+		}
+		linked[[2]int{a, b}] = g.walkable(newNodes[a].Pos, newNodes[b].Pos)
+		return linked[[2]int{a, b}]
+	}
+	var newPaths []Path
+	addPath := func(a, b int) {
+		newPaths = append(newPaths, Path{NodeAID: a, NodeBID: b, Cost: g.cost(newNodes[a].Pos, newNodes[b].Pos)})
+	}
+	for cy := 0; cy < ch; cy++ {
+		for cx := 0; cx < cw; cx++ {
+			a := nodeAt(cx, cy)
+			if a < 0 {
+				continue
+			}
+			// right & down
+			for _, d := range [][2]int{{1, 0}, {0, 1}} {
+				if b := nodeAt(cx+d[0], cy+d[1]); link(a, b) {
+					addPath(a, b)
+				}
+			}
+			// diagonals, unless the way round is there anyway
+			for _, d := range [][2]int{{1, 1}, {-1, 1}} {
+				b := nodeAt(cx+d[0], cy+d[1])
+				if b < 0 {
+					continue
+				}
+				h, v := nodeAt(cx+d[0], cy), nodeAt(cx, cy+1)
+				around := (link(a, h) && link(h, b)) || (link(a, v) && link(v, b))
+				if !around && link(a, b) {
+					addPath(a, b)
+				}
+			}
+		}
+	}
+
+	// drop the nodes nothing connects to and renumber the rest
+	used := make([]bool, len(newNodes))
+	for _, p := range newPaths {
+		used[p.NodeAID], used[p.NodeBID] = true, true
+	}
+	ids := make([]int, len(newNodes))
+	m.Nodes = []Node{}
+	for i, n := range newNodes {
+		if used[i] {
+			ids[i] = len(m.Nodes)
+			m.Nodes = append(m.Nodes, Node{ID: ids[i], Pos: n.Pos})
+		}
+	}
+	m.Paths = make([]Path, len(newPaths))
+	for i, p := range newPaths {
+		m.Paths[i] = Path{NodeAID: ids[p.NodeAID], NodeBID: ids[p.NodeBID], Cost: p.Cost}
+	}
+	return len(m.Nodes), len(m.Paths)
+}
+
+type navGen struct {
+	m     *MapData
+	tiles *TileSet
+	// tiles to the nearest unwalkable one (8-connected), 0 = unwalkable
+	clearance []int
+}
+
+// open reports whether a tile can be walked on.
+func (g *navGen) open(x, y int) bool {
+	return g.m.InBounds(x, y) && g.m.GetTile(x, y) != 0 && !g.m.Solid(x, y, g.tiles)
+}
+
+// computeClearance is a breadth first search out from every unwalkable tile
+// (and the map edge).
+func (g *navGen) computeClearance() []int {
+	w, h := g.m.Width, g.m.Height
+	dist := make([]int, w*h)
+	var queue []int
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			switch {
+			case !g.open(x, y):
+				dist[y*w+x] = 0
+			case x == 0 || y == 0 || x == w-1 || y == h-1:
+				dist[y*w+x] = 1
+			default:
+				dist[y*w+x] = -1
+				continue
+			}
+			queue = append(queue, y*w+x)
+		}
+	}
+	for len(queue) > 0 {
+		i := queue[0]
+		queue = queue[1:]
+		x, y := i%w, i/w
+		for dy := -1; dy <= 1; dy++ {
+			for dx := -1; dx <= 1; dx++ {
+				nx, ny := x+dx, y+dy
+				if nx < 0 || ny < 0 || nx >= w || ny >= h || dist[ny*w+nx] >= 0 {
+					continue
+				}
+				dist[ny*w+nx] = dist[i] + 1
+				queue = append(queue, ny*w+nx)
+			}
+		}
+	}
+	return dist
+}
+
+// pickTile finds the node tile of the cell at (x0, y0).
+func (g *navGen) pickTile(x0, y0, size int) (bx, by int, ok bool) {
+	best, bestCentre := 0, math.MaxFloat64
+	centre := float64(size-1) / 2
+	for y := y0; y < y0+size && y < g.m.Height; y++ {
+		for x := x0; x < x0+size && x < g.m.Width; x++ {
+			c := g.clearance[y*g.m.Width+x]
+			if c <= 0 {
+				continue
+			}
+			d := math.Hypot(float64(x-x0)-centre, float64(y-y0)-centre)
+			if c > best || (c == best && d < bestCentre) {
+				best, bestCentre, bx, by, ok = c, d, x, y, true
+			}
+		}
+	}
+	return bx, by, ok
+}
+
+// walkable reports whether a body moving straight from node a to node b
+// only touches walkable tiles.
+func (g *navGen) walkable(a, b Pos) bool {
+	ok := true
+	g.walk(a, b, func(p Pos, _ float32) {
+		x0, y0 := TileAt(p)
+		x1, y1 := TileAt(Pos{X: p.X + TileSize - 1, Y: p.Y + TileSize - 1})
+		for y := y0; y <= y1; y++ {
+			for x := x0; x <= x1; x++ {
+				if !g.open(x, y) {
+					ok = false
+				}
+			}
+		}
+	})
+	return ok
+}
+
+// cost is the walking time from a to b in distance units: each piece of the
+// line counts length / tile speed.
+func (g *navGen) cost(a, b Pos) float32 {
+	var cost float32
+	g.walk(a, b, func(p Pos, length float32) {
+		// the tile under the middle of the body
+		x, y := TileAt(Pos{X: p.X + TileSize/2, Y: p.Y + TileSize/2})
+		speed := float32(1)
+		if def := g.tiles.Get(g.m.GetTile(x, y)); def != nil {
+			speed = def.Speed()
+		}
+		cost += length / speed
+	})
+	return cost
+}
+
+// walk samples the line from a to b a few times per tile, calling fn with
+// the point and the length of line it stands for.
+func (g *navGen) walk(a, b Pos, fn func(p Pos, length float32)) {
+	d := distance(a, b)
+	steps := int(d/(TileSize/4.0)) + 1
+	for i := 0; i <= steps; i++ {
+		t := float32(i) / float32(steps)
+		length := d / float32(steps)
+		if i == 0 || i == steps {
+			length /= 2 // the ends are shared with the next edge
+		}
+		fn(Pos{X: a.X + t*(b.X-a.X), Y: a.Y + t*(b.Y-a.Y)}, length)
+	}
+}
@@ -0,0 +1,54 @@
+package mapio
+
+// Portal is a trigger area that moves the player to another map (or another
+// spot of the same map). Pos is the top left corner of the area.
+type Portal struct {
+	Name   string  `json:"name"`
+	Pos    Pos     `json:"pos"`
+	Width  float32 `json:"width"`
+	Height float32 `json:"height"`
+	// map file to load, relative to the directory of this map; empty = this map
+	TargetMap string `json:"target_map"`
+	// spawn point to arrive at, empty = "default" or the first one
+	TargetSpawn string `json:"target_spawn"`
+}
+
+// SpawnPoint is a named place where the player arrives on a map.
+type SpawnPoint struct {
+	Name string `json:"name"`
+	Pos  Pos    `json:"pos"`
+}
+
+// DefaultSpawn is the spawn point used when a portal doesn't name one.
+const DefaultSpawn = "default"
+
+// Contains reports whether a world position is inside the portal.
+func (p *Portal) Contains(pos Pos) bool {
+	return pos.X >= p.Pos.X && pos.X < p.Pos.X+p.Width &&
+		pos.Y >= p.Pos.Y && pos.Y < p.Pos.Y+p.Height
+}
+
+// Center returns the middle of the portal area.
+func (p *Portal) Center() Pos {
+	return Pos{X: p.Pos.X + p.Width/2, Y: p.Pos.Y + p.Height/2}
+}
+
+// FindSpawn returns the spawn point called name. An empty name (or an unknown
+// one) falls back on DefaultSpawn, then on the first spawn point; nil if the
+// map has none.
+func (m *MapData) FindSpawn(name string) *SpawnPoint {
+	for _, want := range []string{name, DefaultSpawn} {
+		if want == "" {
+			continue
+		}
+		for i := range m.SpawnPoints {
+			if m.SpawnPoints[i].Name == want {
+				return &m.SpawnPoints[i]
+			}
+		}
+	}
+	if len(m.SpawnPoints) > 0 {
+		return &m.SpawnPoints[0]
+	}
+	return nil
+}
@@ -0,0 +1,651 @@
+package mapio
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Importing maps made with Tiled (https://www.mapeditor.org), .tmx (XML) or
+// .tmj (JSON). Only finite orthogonal maps with embedded tile data are read.
+//
+// Tile layers: a layer goes to the mapio layer named by its "layer" property,
+// or by its own name (ground, detail, overlay, collision); the first other
+// tile layer becomes the ground. A tile's value is its index in its tileset
+// unless the tile has an int "tile" property.
+//
+// Objects are picked by their type (class): "node" (int "id" property, optional),
+// "path" (polyline, every point snaps to a node within half a tile or makes
+// a new one, "cost" property or the length), "npc" (name, "dialogues" lines
+// split by | or newlines, "voice_key", "sprite_path"), "spawner" ("radius",
+// "max_alive", "interval_seconds"), "sprite" ("sprite_type", default 0),
+// "portal" (rectangle, "target_map", "target_spawn") and "spawn" (spawn point
+// named like the object).
+// Untyped polylines are paths too. Positions are scaled from Tiled pixels to
+// world units (TileSize per tile).
+
+// tile gid flags for flipped/rotated tiles
+const tiledFlagMask = 0x0fffffff
+
+// tiledMap is a Tiled map with the parts we read, from either format.
+type tiledMap struct {
+	width, height         int
+	tileWidth, tileHeight int
+	tilesets              []tiledTileset
+	layers                []tiledLayer // tile & object layers, groups flattened
+}
+
+type tiledTileset struct {
+	firstGID int
+	// local tile id -> "tile" property
+	values map[int]int
+}
+
+type tiledLayer struct {
+	name    string
+	objects bool // object layer, else tile layer
+	gids    []uint32
+	items   []tiledObject
+	props   map[string]string
+}
+
+type tiledObject struct {
+	name, kind          string
+	x, y, width, height float64
+	gid                 uint32
+	polyline            []Pos // relative to x, y
+	props               map[string]string
+}
+
+// DecodeTMX parses a Tiled XML map.
+func DecodeTMX(data []byte) (*MapData, error) {
+	var doc tmxMap
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid TMX map: %v", err)
+	}
+	tm, err := doc.convert()
+	if err != nil {
+		return nil, err
+	}
+	return tm.toMapData()
+}
+
+// DecodeTMJ parses a Tiled JSON map.
+func DecodeTMJ(data []byte) (*MapData, error) {
+	var doc tmjMap
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid TMJ map: %v", err)
+	}
+	tm, err := doc.convert()
+	if err != nil {
+		return nil, err
+	}
+	return tm.toMapData()
+}
+
+// isTMJ reports whether a JSON document is a Tiled map.
+func isTMJ(data []byte) bool {
+	var header struct {
+		Type         string `json:"type"`
+		TiledVersion string `json:"tiledversion"`
+	}
+	if json.Unmarshal(data, &header) != nil {
+		return false
+	}
+	return header.Type == "map" || header.TiledVersion != ""
+}
+
+// toMapData converts the Tiled map.
+func (tm *tiledMap) toMapData() (*MapData, error) {
+	if tm.width <= 0 || tm.height <= 0 || tm.tileWidth <= 0 || tm.tileHeight <= 0 {
+		return nil, fmt.Errorf("invalid Tiled map size %dx%d (tiles %dx%d px)", tm.width, tm.height, tm.tileWidth, tm.tileHeight)
+	}
+	m := NewMapData(tm.width, tm.height)
+	m.Spawners = []EnemySpawner{}
+
+	ground := false
+	for _, l := range tm.layers {
+		if l.objects {
+			continue
+		}
+		name := l.props["layer"]
+		if name == "" && IsLayerName(strings.ToLower(l.name)) {
+			name = strings.ToLower(l.name)
+		}
+		if name == "" && !ground {
+			name = LayerGround
+		}
+		if !IsLayerName(name) {
+			fmt.Printf("Warning: Tiled layer %q has no matching map layer, skipped\n", l.name)
+			continue
+		}
+		if name == LayerGround {
+			ground = true
+		}
+		if len(l.gids) != tm.width*tm.height {
+			return nil, fmt.Errorf("Tiled layer %q has %d tiles, expected %d", l.name, len(l.gids), tm.width*tm.height)
+		}
+		for i, gid := range l.gids {
+			x, y := i%tm.width, i/tm.width
+			if name == LayerGround {
+				m.SetTile(x, y, tm.tileValue(gid))
+			} else {
+				m.SetLayerTile(name, x, y, tm.tileValue(gid))
+			}
+		}
+	}
+
+	// nodes first so path points can snap to them
+	var objects []tiledObject
+	for _, l := range tm.layers {
+		if l.objects {
+			objects = append(objects, l.items...)
+		}
+	}
+	for _, o := range objects {
+		if o.kind == "node" {
+			id, err := propInt(o.props, "id", m.GetNextNodeID())
+			if err != nil {
+				return nil, fmt.Errorf("node %q: %v", o.name, err)
+			}
+			m.AddNode(id, tm.objectPos(o).X, tm.objectPos(o).Y)
+		}
+	}
+	for _, o := range objects {
+		if err := tm.addObject(m, o); err != nil {
+			return nil, fmt.Errorf("object %q (%s): %v", o.name, o.kind, err)
+		}
+	}
+	return m, nil
+}
+
+func (tm *tiledMap) addObject(m *MapData, o tiledObject) error {
+	pos := tm.objectPos(o)
+	switch {
+	case o.kind == "node":
+		// done above
+	case o.kind == "path" || (o.kind == "" && len(o.polyline) > 0):
+		if len(o.polyline) < 2 {
+			return fmt.Errorf("a path needs a polyline with at least two points")
+		}
+		prev := -1
+		for _, p := range o.polyline {
+			id := tm.snapNode(m, Pos{X: tm.worldX(o.x + float64(p.X)), Y: tm.worldY(o.y + float64(p.Y))})
+			if prev >= 0 && prev != id {
+				a, b := m.FindNodeByID(prev), m.FindNodeByID(id)
+				cost, err := propFloat(o.props, "cost", distance(a.Pos, b.Pos))
+				if err != nil {
+					return err
+				}
+				m.AddPathWithCost(prev, id, cost)
+			}
+			prev = id
+		}
+	case o.kind == "npc":
+		npc := NPC{Name: o.name, Pos: pos, Dialogues: []string{}, VoiceKey: o.props["voice_key"], SpritePath: o.props["sprite_path"]}
+		for _, line := range strings.FieldsFunc(o.props["dialogues"], func(r rune) bool { return r == '|' || r == '\n' }) {
+			if line = strings.TrimSpace(line); line != "" {
+				npc.Dialogues = append(npc.Dialogues, line)
+			}
+		}
+		m.NPCs = append(m.NPCs, npc)
+	case o.kind == "spawner":
+		sp := EnemySpawner{Pos: pos}
+		var err error
+		if sp.Radius, err = propFloat(o.props, "radius", 120); err != nil {
+			return err
+		}
+		if sp.MaxAlive, err = propInt(o.props, "max_alive", 3); err != nil {
+			return err
+		}
+		if sp.IntervalSeconds, err = propFloat(o.props, "interval_seconds", 5); err != nil {
+			return err
+		}
+		m.Spawners = append(m.Spawners, sp)
+	case o.kind == "portal":
+		m.Portals = append(m.Portals, Portal{
+			Name:        o.name,
+			Pos:         Pos{X: tm.worldX(o.x), Y: tm.worldY(o.y)},
+			Width:       tm.worldX(o.width),
+			Height:      tm.worldY(o.height),
+			TargetMap:   o.props["target_map"],
+			TargetSpawn: o.props["target_spawn"],
+		})
+	case o.kind == "spawn":
+		m.SpawnPoints = append(m.SpawnPoints, SpawnPoint{Name: o.name, Pos: pos})
+	case o.kind == "sprite":
+		t, err := propInt(o.props, "sprite_type", 0)
+		if err != nil {
+			return err
+		}
+		m.AddSprite(t, pos.X, pos.Y)
+	default:
+		fmt.Printf("Warning: Tiled object %q of type %q skipped\n", o.name, o.kind)
+	}
+	return nil
+}
+
+// snapNode returns the node within half a tile of p, adding one if there's none.
+func (tm *tiledMap) snapNode(m *MapData, p Pos) int {
+	for _, n := range m.Nodes {
+		if distance(n.Pos, p) <= TileSize/2 {
+			return n.ID
+		}
+	}
+	id := m.GetNextNodeID()
+	m.AddNode(id, p.X, p.Y)
+	return id
+}
+
+// tileValue turns a gid into a tile value.
+func (tm *tiledMap) tileValue(gid uint32) int {
+	gid &= tiledFlagMask
+	if gid == 0 {
+		return 0
+	}
+	// tilesets are sorted by first gid, the last one that starts before gid has it
+	for i := len(tm.tilesets) - 1; i >= 0; i-- {
+		ts := tm.tilesets[i]
+		if int(gid) >= ts.firstGID {
+			local := int(gid) - ts.firstGID
+			if v, ok := ts.values[local]; ok {
+				return v
+			}
+			return local
+		}
+	}
+	return int(gid)
+}
+
+// objectPos is the world position of an object: points as they are, tile
+// objects (anchored bottom left) and shapes by their center.
+func (tm *tiledMap) objectPos(o tiledObject) Pos {
+	x, y := o.x, o.y
+	switch {
+	case o.gid != 0:
+		x, y = x+o.width/2, y-o.height/2
+	case len(o.polyline) == 0:
+		x, y = x+o.width/2, y+o.height/2
+	}
+	return Pos{X: tm.worldX(x), Y: tm.worldY(y)}
+}
+
+func (tm *tiledMap) worldX(px float64) float32 {
+	return float32(px * TileSize / float64(tm.tileWidth))
+}
+
+func (tm *tiledMap) worldY(px float64) float32 {
+	return float32(px * TileSize / float64(tm.tileHeight))
+}
+
+func distance(a, b Pos) float32 {
+	return float32(math.Hypot(float64(a.X-b.X), float64(a.Y-b.Y)))
+}
+
+func propInt(props map[string]string, name string, def int) (int, error) {
+	s, ok := props[name]
+	if !ok {
+		return def, nil
+	}
+	v, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("property %s: %q is not an int", name, s)
+	}
+	return v, nil
+}
+
+func propFloat(props map[string]string, name string, def float32) (float32, error) {
+	s, ok := props[name]
+	if !ok {
+		return def, nil
+	}
+	v, err := strconv.ParseFloat(strings.TrimSpace(s), 32)
+	if err != nil {
+		return 0, fmt.Errorf("property %s: %q is not a number", name, s)
+	}
+	return float32(v), nil
+}
+
+// decodeTileData decodes csv or base64 (optionally gzip/zlib compressed) layer data.
+func decodeTileData(encoding, compression, data string) ([]uint32, error) {
+	switch encoding {
+	case "csv":
+		var gids []uint32
+		for _, f := range strings.FieldsFunc(data, func(r rune) bool { return r == ',' || r == '\n' || r == '\r' || r == ' ' || r == '\t' }) {
+			v, err := strconv.ParseUint(f, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("invalid tile %q", f)
+			}
+			gids = append(gids, uint32(v))
+		}
+		return gids, nil
+	case "base64":
+		raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(data))
+		if err != nil {
+			return nil, fmt.Errorf("invalid base64 tile data: %v", err)
+		}
+		var r io.Reader = bytes.NewReader(raw)
+		switch compression {
+		case "":
+		case "gzip":
+			if r, err = gzip.NewReader(r); err != nil {
+				return nil, err
+			}
+		case "zlib":
+			if r, err = zlib.NewReader(r); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("unsupported compression %q (use csv, gzip or zlib)", compression)
+		}
+		if raw, err = io.ReadAll(r); err != nil {
+			return nil, err
+		}
+		gids := make([]uint32, len(raw)/4)
+		for i := range gids {
+			gids[i] = binary.LittleEndian.Uint32(raw[i*4:])
+		}
+		return gids, nil
+	}
+	return nil, fmt.Errorf("unsupported tile encoding %q", encoding)
+}
+
+// TMJ (JSON) layout
+
+type tmjMap struct {
+	Width       int          `json:"width"`
+	Height      int          `json:"height"`
+	TileWidth   int          `json:"tilewidth"`
+	TileHeight  int          `json:"tileheight"`
+	Infinite    bool         `json:"infinite"`
+	Orientation string       `json:"orientation"`
+	Layers      []tmjLayer   `json:"layers"`
+	Tilesets    []tmjTileset `json:"tilesets"`
+}
+
+type tmjLayer struct {
+	Name        string          `json:"name"`
+	Type        string          `json:"type"`
+	Data        json.RawMessage `json:"data"`
+	Encoding    string          `json:"encoding"`
+	Compression string          `json:"compression"`
+	Objects     []tmjObject     `json:"objects"`
+	Layers      []tmjLayer      `json:"layers"`
+	Properties  []tmjProperty   `json:"properties"`
+}
+
+type tmjTileset struct {
+	FirstGID int    `json:"firstgid"`
+	Source   string `json:"source"`
+	Tiles    []struct {
+		ID         int           `json:"id"`
+		Properties []tmjProperty `json:"properties"`
+	} `json:"tiles"`
+}
+
+type tmjObject struct {
+	Name       string        `json:"name"`
+	Type       string        `json:"type"`
+	Class      string        `json:"class"`
+	X          float64       `json:"x"`
+	Y          float64       `json:"y"`
+	Width      float64       `json:"width"`
+	Height     float64       `json:"height"`
+	GID        uint32        `json:"gid"`
+	Polyline   []Pos         `json:"polyline"`
+	Properties []tmjProperty `json:"properties"`
+}
+
+type tmjProperty struct {
+	Name  string `json:"name"`
+	Value any    `json:"value"`
+}
+
+func tmjProps(list []tmjProperty) map[string]string {
+	props := map[string]string{}
+	for _, p := range list {
+		props[p.Name] = fmt.Sprint(p.Value)
+	}
+	return props
+}
+
+func (doc *tmjMap) convert() (*tiledMap, error) {
+	if doc.Infinite {
+		return nil, fmt.Errorf("infinite Tiled maps aren't supported")
+	}
+	if doc.Orientation != "" && doc.Orientation != "orthogonal" {
+		return nil, fmt.Errorf("%s Tiled maps aren't supported", doc.Orientation)
+	}
+	tm := &tiledMap{width: doc.Width, height: doc.Height, tileWidth: doc.TileWidth, tileHeight: doc.TileHeight}
+	for _, ts := range doc.Tilesets {
+		t := tiledTileset{firstGID: ts.FirstGID, values: map[int]int{}}
+		for _, tile := range ts.Tiles {
+			if v, err := propInt(tmjProps(tile.Properties), "tile", -1); err == nil && v >= 0 {
+				t.values[tile.ID] = v
+			}
+		}
+		tm.tilesets = append(tm.tilesets, t)
+	}
+	sort.Slice(tm.tilesets, func(i, j int) bool { return tm.tilesets[i].firstGID < tm.tilesets[j].firstGID })
+
+	var walk func(layers []tmjLayer) error
+	walk = func(layers []tmjLayer) error {
+		for _, l := range layers {
+			tl := tiledLayer{name: l.Name, props: tmjProps(l.Properties)}
+			switch l.Type {
+			case "group":
+				if err := walk(l.Layers); err != nil {
+					return err
+				}
+				continue
+			case "tilelayer":
+				if l.Encoding == "base64" {
+					var s string
+					if err := json.Unmarshal(l.Data, &s); err != nil {
+						return fmt.Errorf("layer %q: %v", l.Name, err)
+					}
+					gids, err := decodeTileData("base64", l.Compression, s)
+					if err != nil {
+						return fmt.Errorf("layer %q: %v", l.Name, err)
+					}
+					tl.gids = gids
+				} else if err := json.Unmarshal(l.Data, &tl.gids); err != nil {
+					return fmt.Errorf("layer %q: %v", l.Name, err)
+				}
+			case "objectgroup":
+				tl.objects = true
+				for _, o := range l.Objects {
+					kind := o.Type
+					if kind == "" {
+						kind = o.Class
+					}
+					tl.items = append(tl.items, tiledObject{
+						name: o.Name, kind: strings.ToLower(kind),
+						x: o.X, y: o.Y, width: o.Width, height: o.Height,
+						gid: o.GID, polyline: o.Polyline, props: tmjProps(o.Properties),
+					})
+				}
+			default:
+				continue // image layers
+			}
+			tm.layers = append(tm.layers, tl)
+		}
+		return nil
+	}
+	if err := walk(doc.Layers); err != nil {
+		return nil, err
+	}
+	return tm, nil
+}
+
+// TMX (XML) layout
+
+type tmxMap struct {
+	Width       int          `xml:"width,attr"`
+	Height      int          `xml:"height,attr"`
+	TileWidth   int          `xml:"tilewidth,attr"`
+	TileHeight  int          `xml:"tileheight,attr"`
+	Infinite    int          `xml:"infinite,attr"`
+	Orientation string       `xml:"orientation,attr"`
+	Tilesets    []tmxTileset `xml:"tileset"`
+	// layer, objectgroup & group elements in file order
+	Layers []tmxLayer `xml:",any"`
+}
+
+type tmxTileset struct {
+	FirstGID int `xml:"firstgid,attr"`
+	Tiles    []struct {
+		ID         int           `xml:"id,attr"`
+		Properties []tmxProperty `xml:"properties>property"`
+	} `xml:"tile"`
+}
+
+type tmxLayer struct {
+	XMLName    xml.Name
+	Name       string        `xml:"name,attr"`
+	Properties []tmxProperty `xml:"properties>property"`
+	Data       struct {
+		Encoding    string `xml:"encoding,attr"`
+		Compression string `xml:"compression,attr"`
+		Text        string `xml:",chardata"`
+		Tiles       []struct {
+			GID uint32 `xml:"gid,attr"`
+		} `xml:"tile"`
+	} `xml:"data"`
+	Objects []tmxObject `xml:"object"`
+	Layers  []tmxLayer  `xml:",any"`
+}
+
+type tmxObject struct {
+	Name       string        `xml:"name,attr"`
+	Type       string        `xml:"type,attr"`
+	Class      string        `xml:"class,attr"`
+	X          float64       `xml:"x,attr"`
+	Y          float64       `xml:"y,attr"`
+	Width      float64       `xml:"width,attr"`
+	Height     float64       `xml:"height,attr"`
+	GID        uint32        `xml:"gid,attr"`
+	Properties []tmxProperty `xml:"properties>property"`
+	Polyline   *struct {
+		Points string `xml:"points,attr"`
+	} `xml:"polyline"`
+}
+
+type tmxProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+	// multi-line strings are stored as the element text
+	Text string `xml:",chardata"`
+}
+
+func tmxProps(list []tmxProperty) map[string]string {
+	props := map[string]string{}
+	for _, p := range list {
+		if p.Value == "" {
+			props[p.Name] = p.Text
+		} else {
+			props[p.Name] = p.Value
+		}
+	}
+	return props
+}
+
+func (doc *tmxMap) convert() (*tiledMap, error) {
+	if doc.Infinite != 0 {
+		return nil, fmt.Errorf("infinite Tiled maps aren't supported")
+	}
+	if doc.Orientation != "" && doc.Orientation != "orthogonal" {
+		return nil, fmt.Errorf("%s Tiled maps aren't supported", doc.Orientation)
+	}
+	tm := &tiledMap{width: doc.Width, height: doc.Height, tileWidth: doc.TileWidth, tileHeight: doc.TileHeight}
+	for _, ts := range doc.Tilesets {
+		t := tiledTileset{firstGID: ts.FirstGID, values: map[int]int{}}
+		for _, tile := range ts.Tiles {
+			if v, err := propInt(tmxProps(tile.Properties), "tile", -1); err == nil && v >= 0 {
+				t.values[tile.ID] = v
+			}
+		}
+		tm.tilesets = append(tm.tilesets, t)
+	}
+	sort.Slice(tm.tilesets, func(i, j int) bool { return tm.tilesets[i].firstGID < tm.tilesets[j].firstGID })
+
+	var walk func(layers []tmxLayer) error
+	walk = func(layers []tmxLayer) error {
+		for _, l := range layers {
+			tl := tiledLayer{name: l.Name, props: tmxProps(l.Properties)}
+			switch l.XMLName.Local {
+			case "group":
+				if err := walk(l.Layers); err != nil {
+					return err
+				}
+				continue
+			case "layer":
+				if l.Data.Encoding == "" {
+					for _, t := range l.Data.Tiles {
+						tl.gids = append(tl.gids, t.GID)
+					}
+				} else {
+					gids, err := decodeTileData(l.Data.Encoding, l.Data.Compression, l.Data.Text)
+					if err != nil {
+						return fmt.Errorf("layer %q: %v", l.Name, err)
+					}
+					tl.gids = gids
+				}
+			case "objectgroup":
+				tl.objects = true
+				for _, o := range l.Objects {
+					kind := o.Type
+					if kind == "" {
+						kind = o.Class
+					}
+					obj := tiledObject{
+						name: o.Name, kind: strings.ToLower(kind),
+						x: o.X, y: o.Y, width: o.Width, height: o.Height,
+						gid: o.GID, props: tmxProps(o.Properties),
+					}
+					if o.Polyline != nil {
+						points, err := parseTMXPoints(o.Polyline.Points)
+						if err != nil {
+							return fmt.Errorf("object %q: %v", o.Name, err)
+						}
+						obj.polyline = points
+					}
+					tl.items = append(tl.items, obj)
+				}
+			default:
+				continue // image layers, editor settings...
+			}
+			tm.layers = append(tm.layers, tl)
+		}
+		return nil
+	}
+	if err := walk(doc.Layers); err != nil {
+		return nil, err
+	}
+	return tm, nil
+}
+
+// parseTMXPoints parses "x1,y1 x2,y2 ...".
+func parseTMXPoints(s string) ([]Pos, error) {
+	var points []Pos
+	for _, pair := range strings.Fields(s) {
+		xs, ys, ok := strings.Cut(pair, ",")
+		x, xerr := strconv.ParseFloat(xs, 32)
+		y, yerr := strconv.ParseFloat(ys, 32)
+		if !ok || xerr != nil || yerr != nil {
+			return nil, fmt.Errorf("invalid polyline point %q", pair)
+		}
+		points = append(points, Pos{X: float32(x), Y: float32(y)})
+	}
+	return points, nil
+}
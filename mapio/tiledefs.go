@@ -0,0 +1,170 @@
+package mapio
+
+import (
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// TileDef describes one tile id: how it behaves in the game, how it is
+// drawn and how the editor shows it. Definitions live in a JSON file
+// (import/tiles.json) shared by the game and the editor.
+type TileDef struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	// layers whose palette offers this tile (default: ground only)
+	Layers []string `json:"layers,omitempty"`
+
+	Solid bool `json:"solid,omitempty"`
+	// movement speed factor while standing on the tile (0 means 1)
+	SpeedMultiplier float32 `json:"speed_multiplier,omitempty"`
+	// damage dealt to a player standing on the tile
+	DamagePerSecond float32 `json:"damage_per_second,omitempty"`
+
+	// texture variants, one is picked per tile at random (by weight)
+	Variants []TileVariant `json:"variants,omitempty"`
+	// picks the texture from the neighbours instead, see Autotile
+	Autotile *Autotile `json:"autotile,omitempty"`
+
+	// "#rrggbb", used by the editor when there's no texture
+	EditorColor string `json:"editor_color,omitempty"`
+}
+
+// TileVariant is one texture of a tile with its relative pick weight.
+type TileVariant struct {
+	Texture string `json:"texture"`
+	Weight  int    `json:"weight,omitempty"` // 0 means 1
+}
+
+// Autotile picks a texture from the four neighbours (up, left, right, down).
+// Template contains "{mask}", which is replaced by one letter per neighbour:
+// Same if the neighbour is the same tile, Other if not. E.g. with
+// Template "import/tiles/Dry2Grass_{mask}.png", Same "D" and Other "G" a
+// tile with dry above and grass elsewhere uses Dry2Grass_DGGG.png.
+type Autotile struct {
+	Template string `json:"template"`
+	Same     string `json:"same"`
+	Other    string `json:"other"`
+}
+
+// Mask builds the neighbour mask for Template.
+func (a *Autotile) Mask(up, left, right, down bool) string {
+	var b strings.Builder
+	for _, same := range []bool{up, left, right, down} {
+		if same {
+			b.WriteString(a.Same)
+		} else {
+			b.WriteString(a.Other)
+		}
+	}
+	return b.String()
+}
+
+// Texture returns the texture path for a neighbour mask.
+func (a *Autotile) Texture(mask string) string {
+	return strings.ReplaceAll(a.Template, "{mask}", mask)
+}
+
+// Speed returns the effective speed multiplier.
+func (d *TileDef) Speed() float32 {
+	if d.SpeedMultiplier == 0 {
+		return 1
+	}
+	return d.SpeedMultiplier
+}
+
+// OnLayer reports whether the tile belongs to a layer's palette.
+func (d *TileDef) OnLayer(layer string) bool {
+	if len(d.Layers) == 0 {
+		return layer == LayerGround
+	}
+	for _, l := range d.Layers {
+		if l == layer {
+			return true
+		}
+	}
+	return false
+}
+
+// Color parses EditorColor, falling back on gray.
+func (d *TileDef) Color() color.RGBA {
+	c := color.RGBA{128, 128, 128, 255}
+	s := strings.TrimPrefix(d.EditorColor, "#")
+	if len(s) != 6 {
+		return c
+	}
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return c
+	}
+	c.R, c.G, c.B = uint8(v>>16), uint8(v>>8), uint8(v)
+	return c
+}
+
+// TileSet is the loaded list of tile definitions.
+type TileSet struct {
+	Tiles []TileDef `json:"tiles"`
+	byID  map[int]*TileDef
+}
+
+// LoadTileSet reads and checks a tile definition file.
+func LoadTileSet(filename string) (*TileSet, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error opening tile definitions: %v", err)
+	}
+	return ParseTileSet(data)
+}
+
+// ParseTileSet decodes and checks tile definitions.
+func ParseTileSet(data []byte) (*TileSet, error) {
+	var ts TileSet
+	if err := json.Unmarshal(data, &ts); err != nil {
+		return nil, fmt.Errorf("invalid tile definitions: %v", err)
+	}
+	ts.byID = make(map[int]*TileDef, len(ts.Tiles))
+	for i := range ts.Tiles {
+		d := &ts.Tiles[i]
+		if d.ID < 0 {
+			return nil, fmt.Errorf("tile %q: negative id %d", d.Name, d.ID)
+		}
+		if prev, ok := ts.byID[d.ID]; ok {
+			return nil, fmt.Errorf("tile id %d used by both %q and %q", d.ID, prev.Name, d.Name)
+		}
+		if d.Autotile != nil && !strings.Contains(d.Autotile.Template, "{mask}") {
+			return nil, fmt.Errorf("tile %q: autotile template has no {mask}", d.Name)
+		}
+		for _, l := range d.Layers {
+			if !IsLayerName(l) || l == LayerCollision {
+				return nil, fmt.Errorf("tile %q: invalid layer %q", d.Name, l)
+			}
+		}
+		ts.byID[d.ID] = d
+	}
+	return &ts, nil
+}
+
+// Get returns the definition of a tile id, nil if unknown.
+func (ts *TileSet) Get(id int) *TileDef {
+	if ts == nil {
+		return nil
+	}
+	return ts.byID[id]
+}
+
+// ForLayer lists the tiles offered by a layer's palette, in file order.
+func (ts *TileSet) ForLayer(layer string) []*TileDef {
+	if ts == nil {
+		return nil
+	}
+	var list []*TileDef
+	for i := range ts.Tiles {
+		if ts.Tiles[i].OnLayer(layer) {
+			list = append(list, &ts.Tiles[i])
+		}
+	}
+	return list
+}
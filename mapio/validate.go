@@ -0,0 +1,375 @@
+package mapio
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// TileSize is the size of a tile in world units (the game's screendivisor and
+// the editor's tileSize). Tile (x, y) covers [x*TileSize, (x+1)*TileSize).
+const TileSize = 30
+
+// TileMountain is the id that blocks movement when no tile definitions are
+// available.
+const TileMountain = 1
+
+// Severity of a diagnostic. Errors are things the game can't use as intended
+// (a path to a missing node), warnings are likely mistakes.
+type Severity int
+
+const (
+	SeverityWarning Severity = iota
+	SeverityError
+)
+
+func (s Severity) String() string {
+	if s == SeverityError {
+		return "error"
+	}
+	return "warning"
+}
+
+// Diagnostic codes
+const (
+	DiagRaggedRow       = "ragged-row"
+	DiagLayerSize       = "layer-size"
+	DiagUnknownLayer    = "unknown-layer"
+	DiagUnknownTile     = "unknown-tile"
+	DiagDuplicateNode   = "duplicate-node"
+	DiagDanglingPath    = "dangling-path"
+	DiagSelfPath        = "self-path"
+	DiagDuplicatePath   = "duplicate-path"
+	DiagDisconnectedNav = "disconnected-nav"
+	DiagOutOfBounds     = "out-of-bounds"
+	DiagOnSolidTile     = "on-solid-tile"
+	DiagBadSpawner      = "bad-spawner"
+	DiagBadPortal       = "bad-portal"
+	DiagDuplicateSpawn  = "duplicate-spawn"
+)
+
+// Diagnostic is one problem found by Validate.
+type Diagnostic struct {
+	Severity Severity
+	Code     string
+	Message  string
+	// world position the problem is about, if any
+	Pos    Pos
+	HasPos bool
+}
+
+func (d Diagnostic) String() string {
+	if d.HasPos {
+		return fmt.Sprintf("%s: %s (%.0f, %.0f): %s", d.Severity, d.Code, d.Pos.X, d.Pos.Y, d.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", d.Severity, d.Code, d.Message)
+}
+
+// Diagnostics is the result of Validate, errors first.
+type Diagnostics []Diagnostic
+
+// HasErrors reports whether any diagnostic is an error.
+func (ds Diagnostics) HasErrors() bool {
+	for _, d := range ds {
+		if d.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// Count returns the number of errors and warnings.
+func (ds Diagnostics) Count() (errors, warnings int) {
+	for _, d := range ds {
+		if d.Severity == SeverityError {
+			errors++
+		} else {
+			warnings++
+		}
+	}
+	return errors, warnings
+}
+
+func (ds Diagnostics) String() string {
+	var b strings.Builder
+	for _, d := range ds {
+		b.WriteString(d.String())
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// Validate checks a map for broken references and likely mistakes. Without
+// tile definitions only mountains and the collision layer count as solid,
+// see ValidateWithTiles.
+func Validate(m *MapData) Diagnostics {
+	return ValidateWithTiles(m, nil)
+}
+
+// ValidateWithTiles is Validate using tile definitions to decide which tiles
+// are solid and which ids are unknown.
+func ValidateWithTiles(m *MapData, tiles *TileSet) Diagnostics {
+	v := validator{m: m, tiles: tiles}
+	v.checkGrid()
+	v.checkNav()
+	v.checkEntities()
+	v.checkPortals()
+
+	sort.SliceStable(v.diags, func(i, j int) bool {
+		return v.diags[i].Severity > v.diags[j].Severity
+	})
+	return v.diags
+}
+
+// TileAt returns the tile a world position is on.
+func TileAt(p Pos) (x, y int) {
+	x, y = int(p.X/TileSize), int(p.Y/TileSize)
+	// int() truncates towards zero, -0.5 would land on tile 0
+	if p.X < 0 {
+		x--
+	}
+	if p.Y < 0 {
+		y--
+	}
+	return x, y
+}
+
+// InBounds reports whether a tile is on the map.
+func (m *MapData) InBounds(x, y int) bool {
+	return x >= 0 && y >= 0 && x < m.Width && y < m.Height
+}
+
+// Solid reports whether a tile blocks movement, like the game does: the
+// collision layer decides if it says so, otherwise the ground tile. With a
+// nil tile set only mountains are solid.
+func (m *MapData) Solid(x, y int, tiles *TileSet) bool {
+	switch m.GetLayerTile(LayerCollision, x, y) {
+	case CollisionBlocked:
+		return true
+	case CollisionWalkable:
+		return false
+	}
+	id := m.GetTile(x, y)
+	if tiles == nil {
+		return id == TileMountain
+	}
+	def := tiles.Get(id)
+	return def != nil && def.Solid
+}
+
+type validator struct {
+	m     *MapData
+	tiles *TileSet
+	diags Diagnostics
+}
+
+func (v *validator) add(sev Severity, code, format string, args ...any) {
+	v.diags = append(v.diags, Diagnostic{Severity: sev, Code: code, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) addAt(p Pos, sev Severity, code, format string, args ...any) {
+	v.diags = append(v.diags, Diagnostic{Severity: sev, Code: code, Message: fmt.Sprintf(format, args...), Pos: p, HasPos: true})
+}
+
+func (v *validator) checkGrid() {
+	m := v.m
+	if len(m.Tiles) != m.Height {
+		v.add(SeverityError, DiagRaggedRow, "map has %d rows, expected height %d", len(m.Tiles), m.Height)
+	}
+	for y, row := range m.Tiles {
+		if len(row) != m.Width {
+			v.addAt(Pos{0, float32(y * TileSize)}, SeverityError, DiagRaggedRow, "row %d has %d tiles, expected width %d", y, len(row), m.Width)
+		}
+	}
+
+	seen := map[string]bool{}
+	for _, l := range m.Layers {
+		if !IsLayerName(l.Name) || l.Name == LayerGround {
+			v.add(SeverityWarning, DiagUnknownLayer, "unknown layer %q is ignored", l.Name)
+			continue
+		}
+		if seen[l.Name] {
+			v.add(SeverityWarning, DiagUnknownLayer, "layer %q appears more than once, only the first is used", l.Name)
+		}
+		seen[l.Name] = true
+		if len(l.Tiles) != m.Height {
+			v.add(SeverityWarning, DiagLayerSize, "layer %q has %d rows, expected %d", l.Name, len(l.Tiles), m.Height)
+		}
+		for y, row := range l.Tiles {
+			if len(row) != m.Width {
+				v.add(SeverityWarning, DiagLayerSize, "layer %q row %d has %d tiles, expected %d", l.Name, y, len(row), m.Width)
+				break
+			}
+		}
+	}
+
+	if v.tiles == nil {
+		return
+	}
+	// one diagnostic per unknown id, at its first use
+	unknown := map[int]bool{}
+	check := func(layer string, grid [][]int) {
+		for y, row := range grid {
+			for x, id := range row {
+				if id == 0 || unknown[id] || v.tiles.Get(id) != nil {
+					continue
+				}
+				unknown[id] = true
+				v.addAt(Pos{float32(x * TileSize), float32(y * TileSize)}, SeverityWarning, DiagUnknownTile,
+					"%s tile id %d has no definition", layer, id)
+			}
+		}
+	}
+	check(LayerGround, m.Tiles)
+	check(LayerDetail, m.LayerTiles(LayerDetail))
+	check(LayerOverlay, m.LayerTiles(LayerOverlay))
+}
+
+func (v *validator) checkNav() {
+	m := v.m
+	nodes := map[int]Node{}
+	for _, n := range m.Nodes {
+		if _, ok := nodes[n.ID]; ok {
+			v.addAt(n.Pos, SeverityError, DiagDuplicateNode, "node id %d is used more than once", n.ID)
+			continue
+		}
+		nodes[n.ID] = n
+		v.checkPlaced(n.Pos, fmt.Sprintf("node %d", n.ID))
+	}
+
+	// union-find over the valid paths to find the connected components
+	parent := map[int]int{}
+	var find func(id int) int
+	find = func(id int) int {
+		if parent[id] != id {
+			parent[id] = find(parent[id])
+		}
+		return parent[id]
+	}
+	for id := range nodes {
+		parent[id] = id
+	}
+
+	type edge struct{ a, b int }
+	edges := map[edge]bool{}
+	for _, p := range m.Paths {
+		a, aok := nodes[p.NodeAID]
+		_, bok := nodes[p.NodeBID]
+		switch {
+		case !aok || !bok:
+			missing := p.NodeAID
+			if aok {
+				missing = p.NodeBID
+			}
+			v.add(SeverityError, DiagDanglingPath, "path %d-%d references missing node %d", p.NodeAID, p.NodeBID, missing)
+			continue
+		case p.NodeAID == p.NodeBID:
+			v.addAt(a.Pos, SeverityWarning, DiagSelfPath, "path connects node %d to itself", p.NodeAID)
+			continue
+		}
+		e := edge{min(p.NodeAID, p.NodeBID), max(p.NodeAID, p.NodeBID)}
+		if edges[e] {
+			v.addAt(a.Pos, SeverityWarning, DiagDuplicatePath, "path %d-%d is defined more than once", e.a, e.b)
+		}
+		edges[e] = true
+		parent[find(p.NodeAID)] = find(p.NodeBID)
+	}
+
+	// everything outside the biggest component can't be reached from it
+	components := map[int][]int{}
+	for id := range nodes {
+		root := find(id)
+		components[root] = append(components[root], id)
+	}
+	if len(components) < 2 {
+		return
+	}
+	var list [][]int
+	for _, ids := range components {
+		sort.Ints(ids)
+		list = append(list, ids)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if len(list[i]) != len(list[j]) {
+			return len(list[i]) > len(list[j])
+		}
+		return list[i][0] < list[j][0]
+	})
+	for _, ids := range list[1:] {
+		v.addAt(nodes[ids[0]].Pos, SeverityWarning, DiagDisconnectedNav,
+			"nodes %v are not connected to the main nav graph (%d nodes)", ids, len(list[0]))
+	}
+}
+
+func (v *validator) checkEntities() {
+	// sprites are props (trees line the mountains), only their bounds matter
+	for _, s := range v.m.Sprites {
+		if x, y := TileAt(s.Pos); !v.m.InBounds(x, y) {
+			v.addAt(s.Pos, SeverityError, DiagOutOfBounds, "sprite (type %d) is outside the map (tile %d, %d)", s.Type, x, y)
+		}
+	}
+	for _, n := range v.m.NPCs {
+		name := n.Name
+		if name == "" {
+			name = "unnamed"
+		}
+		v.checkPlaced(n.Pos, fmt.Sprintf("NPC %q", name))
+	}
+	for i, sp := range v.m.Spawners {
+		what := fmt.Sprintf("spawner %d", i+1)
+		v.checkPlaced(sp.Pos, what)
+		if sp.MaxAlive <= 0 {
+			v.addAt(sp.Pos, SeverityWarning, DiagBadSpawner, "%s never spawns anything (max alive %d)", what, sp.MaxAlive)
+		}
+		if sp.IntervalSeconds <= 0 {
+			v.addAt(sp.Pos, SeverityWarning, DiagBadSpawner, "%s has a non-positive interval (%g s)", what, sp.IntervalSeconds)
+		}
+		if sp.Radius < 0 {
+			v.addAt(sp.Pos, SeverityWarning, DiagBadSpawner, "%s has a negative radius", what)
+		}
+	}
+}
+
+func (v *validator) checkPortals() {
+	names := map[string]bool{}
+	for _, sp := range v.m.SpawnPoints {
+		what := fmt.Sprintf("spawn point %q", sp.Name)
+		if names[sp.Name] {
+			v.addAt(sp.Pos, SeverityWarning, DiagDuplicateSpawn, "%s is defined more than once", what)
+		}
+		names[sp.Name] = true
+		v.checkPlaced(sp.Pos, what)
+	}
+	for i, p := range v.m.Portals {
+		what := fmt.Sprintf("portal %q", p.Name)
+		if p.Name == "" {
+			what = fmt.Sprintf("portal %d", i+1)
+		}
+		if p.Width <= 0 || p.Height <= 0 {
+			v.addAt(p.Pos, SeverityError, DiagBadPortal, "%s has an empty area (%gx%g)", what, p.Width, p.Height)
+		}
+		if x, y := TileAt(p.Center()); !v.m.InBounds(x, y) {
+			v.addAt(p.Pos, SeverityError, DiagOutOfBounds, "%s is outside the map (tile %d, %d)", what, x, y)
+		}
+		// targets on other maps are checked when they're loaded
+		if p.TargetMap == "" {
+			if p.TargetSpawn == "" {
+				v.addAt(p.Pos, SeverityWarning, DiagBadPortal, "%s has no target map or spawn point", what)
+			} else if !names[p.TargetSpawn] {
+				v.addAt(p.Pos, SeverityError, DiagBadPortal, "%s leads to missing spawn point %q", what, p.TargetSpawn)
+			}
+		}
+	}
+}
+
+// checkPlaced reports things placed outside the map or inside solid tiles.
+func (v *validator) checkPlaced(p Pos, what string) {
+	x, y := TileAt(p)
+	if !v.m.InBounds(x, y) {
+		v.addAt(p, SeverityError, DiagOutOfBounds, "%s is outside the map (tile %d, %d)", what, x, y)
+		return
+	}
+	if v.m.Solid(x, y, v.tiles) {
+		v.addAt(p, SeverityWarning, DiagOnSolidTile, "%s is on a solid tile (%d, %d)", what, x, y)
+	}
+}
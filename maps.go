@@ -0,0 +1,198 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"path/filepath"
+
+	"rpg/mapio"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// length of each half of the fade between maps, in seconds
+const MAP_FADE_TIME = 0.35
+
+// mapTransition is the fade out -> load -> fade in sequence started by a portal.
+type mapTransition struct {
+	active bool
+	// false while fading out, true once the target map is loaded and fading in
+	loaded bool
+	t      float64
+
+	target string // map file, "" = the current map
+	spawn  string
+}
+
+// loadMap replaces the current map with the one in path. Players are kept
+// (the caller puts them at a spawn point), everything else belongs to the
+// old map and is dropped.
+func loadMap(path string) error {
+	// chunked maps are read a chunk at a time, everything else is loaded
+	// whole & chunked in memory (the whole map stays resident, see chunks.go)
+	var md *mapio.MapData
+	var source mapio.ChunkSource
+	if mapio.IsChunkedMap(path) {
+		store, err := mapio.OpenChunkStore(path)
+		if err != nil {
+			return err
+		}
+		md, source = store.Header, store
+		cw, ch := md.ChunkCount(store.Size)
+		fmt.Printf("Opened chunked map: %dx%d in %dx%d chunks\n", md.Width, md.Height, cw, ch)
+	} else {
+		var err error
+		if md, err = mapio.LoadMapFromFile(path); err != nil {
+			return err
+		}
+		source = mapio.NewMemorySource(md, mapio.DefaultChunkSize)
+	}
+
+	players := append([]*character(nil), game.entities.players...)
+	game.entities.clear()
+	for _, c := range players {
+		game.entities.adopt(c)
+	}
+	activeNPC = nil
+	damageIndicators = nil
+
+	game.currentmap = gamemap{
+		path:        path,
+		width:       md.Width,
+		height:      md.Height,
+		portals:     md.Portals,
+		spawnPoints: md.SpawnPoints,
+	}
+	game.currentmap.chunks = createChunkCache(source)
+	// Nodes
+	for _, n := range md.Nodes {
+		node := createNode(n.ID, createPos(n.Pos.X, n.Pos.Y))
+		game.currentmap.nodes = append(game.currentmap.nodes, node)
+	}
+	// Paths
+	for _, p := range md.Paths {
+		a, b := findNodeByID(p.NodeAID), findNodeByID(p.NodeBID)
+		if a == nil || b == nil {
+			continue // dangling, already reported by mapio.Validate
+		}
+		game.currentmap.paths = append(game.currentmap.paths, createPath(a, b, p.Cost))
+	}
+	// Sprites
+	for _, s := range md.Sprites {
+		createSprite(createPos(s.Pos.X, s.Pos.Y), s.Type)
+	}
+	// NPCs
+	for _, npc := range md.NPCs {
+		createNPCWithSprite(createPos(npc.Pos.X, npc.Pos.Y), npc.Dialogues, npc.SpritePath)
+	}
+	// Initialize runtime spawners from map spawners
+	initSpawners(md)
+	return nil
+}
+
+// findSpawn works like mapio.MapData.FindSpawn on the current map.
+func findSpawn(name string) *mapio.SpawnPoint {
+	md := mapio.MapData{SpawnPoints: game.currentmap.spawnPoints}
+	return md.FindSpawn(name)
+}
+
+// placeAtSpawn moves a player onto a spawn point (its center). Without spawn
+// points the player stays where it is.
+func (c *character) placeAtSpawn(name string) {
+	sp := findSpawn(name)
+	if sp == nil {
+		return
+	}
+	c.teleport(createPos(sp.Pos.X-screendivisor/2, sp.Pos.Y-screendivisor/2))
+	c.onTile = false
+	// arriving inside a portal mustn't send the player straight back
+	c.inPortal = c.portalAt() != nil
+}
+
+// portalAt returns the portal the player's center is in, nil if none.
+func (c *character) portalAt() *mapio.Portal {
+	center := mapio.Pos{X: c.pos.float_x + screendivisor/2, Y: c.pos.float_y + screendivisor/2}
+	for i := range game.currentmap.portals {
+		if game.currentmap.portals[i].Contains(center) {
+			return &game.currentmap.portals[i]
+		}
+	}
+	return nil
+}
+
+// updatePortals starts a transition when a player walks into a portal and
+// runs the fade of an ongoing one.
+func updatePortals(dt float64) {
+	tr := &game.transition
+	if tr.active {
+		tr.t += dt
+		if tr.t < MAP_FADE_TIME {
+			return
+		}
+		if tr.loaded {
+			tr.active = false
+			return
+		}
+		changeMap(tr.target, tr.spawn)
+		tr.loaded = true
+		tr.t = 0
+		return
+	}
+
+	for _, c := range game.entities.players {
+		p := c.portalAt()
+		if p == nil {
+			c.inPortal = false
+			continue
+		}
+		if c.inPortal || activeNPC != nil {
+			continue
+		}
+		c.inPortal = true
+		target := ""
+		if p.TargetMap != "" {
+			// relative to the map the portal is on
+			target = filepath.Join(filepath.Dir(game.currentmap.path), filepath.FromSlash(p.TargetMap))
+		}
+		*tr = mapTransition{active: true, target: target, spawn: p.TargetSpawn}
+		return
+	}
+}
+
+// changeMap loads the target map (if it's another one) and puts the players
+// at the spawn point. A map that fails to load keeps the player where it was.
+func changeMap(target, spawn string) {
+	from := game.currentmap.path
+	if target != "" && filepath.Clean(target) != filepath.Clean(from) {
+		if err := loadMap(target); err != nil {
+			fmt.Println("Failed to load map:", err)
+			return
+		}
+	} else {
+		target = from
+	}
+	for _, c := range game.entities.players {
+		c.placeAtSpawn(spawn)
+	}
+	game.events.mapChanged.publish(MapChanged{From: from, To: target, Spawn: spawn})
+}
+
+// inTransition reports whether a map transition is running (player input is ignored).
+func inTransition() bool {
+	return game.transition.active
+}
+
+// drawTransition darkens the screen while fading between maps.
+func drawTransition(screen *ebiten.Image) {
+	tr := game.transition
+	if !tr.active {
+		return
+	}
+	a := float32(tr.t / MAP_FADE_TIME)
+	if tr.loaded {
+		a = 1 - a
+	}
+	a = clampFloat(a, 0, 1)
+	vector.DrawFilledRect(screen, 0, 0, screenWidth, screenHeight, color.RGBA{0, 0, 0, uint8(a * 255)}, false)
+}
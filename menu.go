@@ -5,9 +5,80 @@ import (
 
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
 	"github.com/hajimehoshi/ebiten/v2/vector"
 )
 
+var (
+	// Main menu
+	playbtn    = createButton("Play", 150, 50, uitransparent, uilightgray, uigray, onearg_createPos(25))
+	optionsbtn = createButton("Options", 150, 50, uitransparent, uilightgray, uigray, createPos(25, 85))
+	exitbtn    = createButton("Exit", 150, 50, uitransparent, uilightgray, uigray, createPos(25, 145))
+
+	// Options
+	options_exitbtn = createButton("Back to menu", 150, 50, uitransparent, uilightgray, uigray, onearg_createPos(25))
+	testslider      = createSlider("testslider", 500, 20, 5, 10, uigray, uilightgray, uigray, createPos(230, 80))
+)
+
+// menuScene is the main menu.
+type menuScene struct{}
+
+func (m *menuScene) Enter() {}
+func (m *menuScene) Exit()  {}
+
+func (m *menuScene) Update(dt float64) error {
+	playbtn.UpdateButton()
+	optionsbtn.UpdateButton()
+	exitbtn.UpdateButton()
+	defer func() {
+		// Reset one-shot pressed state so it doesn't trigger repeatedly
+		playbtn.pressed = false
+		optionsbtn.pressed = false
+		exitbtn.pressed = false
+	}()
+
+	updateMenuEffects(dt)
+
+	switch {
+	case playbtn.pressed:
+		game.scenes.reset(&worldScene{})
+	case optionsbtn.pressed:
+		game.scenes.reset(&optionsScene{})
+	case exitbtn.pressed, inpututil.IsKeyJustPressed(ebiten.KeyEscape):
+		exitGame()
+	}
+	return nil
+}
+
+func (m *menuScene) Draw(screen *ebiten.Image) {
+	drawFancyMenu(screen, 0)
+}
+
+// optionsScene is the options submenu.
+type optionsScene struct{}
+
+func (o *optionsScene) Enter() {}
+func (o *optionsScene) Exit()  {}
+
+func (o *optionsScene) Update(dt float64) error {
+	options_exitbtn.UpdateButton()
+	defer func() { options_exitbtn.pressed = false }()
+
+	updateMenuEffects(dt)
+
+	switch {
+	case options_exitbtn.pressed:
+		game.scenes.reset(&menuScene{})
+	case inpututil.IsKeyJustPressed(ebiten.KeyEscape):
+		exitGame()
+	}
+	return nil
+}
+
+func (o *optionsScene) Draw(screen *ebiten.Image) {
+	drawFancyMenu(screen, 1)
+}
+
 // updateMenuEffects kept for compatibility (now a no-op)
 func updateMenuEffects(dt float64) {}
 
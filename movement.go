@@ -1,21 +1,10 @@
 package main
 
-//
-import (
-	"time"
-
-	"github.com/hajimehoshi/ebiten/v2"
-)
+import "rpg/mapio"
 
 // safeTile returns tile value or 0 if out of bounds
 func safeTile(y, x int) int {
-	if y < 0 || y >= game.currentmap.height || y >= len(game.currentmap.data) {
-		return 0
-	}
-	if x < 0 || x >= game.currentmap.width || x >= len(game.currentmap.data[y]) {
-		return 0
-	}
-	return game.currentmap.data[y][x]
+	return layerTile(mapio.LayerGround, y, x)
 }
 
 // 0 up, 1 down, 2 right, 3 left
@@ -50,12 +39,12 @@ func (c *character) checkNextTile(way int) bool {
 		toprightpos.float_y -= 3
 
 		x, y = ptid(topleftpos)
-		if safeTile(y, x) == 1 {
+		if solidTile(y, x) {
 			return false
 		}
 
 		x, y = ptid(toprightpos)
-		if safeTile(y, x) == 1 {
+		if solidTile(y, x) {
 			return false
 		}
 
@@ -66,12 +55,12 @@ func (c *character) checkNextTile(way int) bool {
 		bottomleftpos.float_y += 3
 
 		x, y = ptid(bottomleftpos)
-		if safeTile(y, x) == 1 {
+		if solidTile(y, x) {
 			return false
 		}
 
 		x, y = ptid(bottomrightpos)
-		if safeTile(y, x) == 1 {
+		if solidTile(y, x) {
 			return false
 		}
 
@@ -83,12 +72,12 @@ func (c *character) checkNextTile(way int) bool {
 		toprightpos.float_x += 3
 
 		x, y = ptid(bottomrightpos)
-		if safeTile(y, x) == 1 {
+		if solidTile(y, x) {
 			return false
 		}
 
 		x, y = ptid(toprightpos)
-		if safeTile(y, x) == 1 {
+		if solidTile(y, x) {
 			return false
 		}
 
@@ -100,12 +89,12 @@ func (c *character) checkNextTile(way int) bool {
 		bottomleftpos.float_x -= 3
 
 		x, y = ptid(topleftpos)
-		if safeTile(y, x) == 1 {
+		if solidTile(y, x) {
 			return false
 		}
 
 		x, y = ptid(bottomleftpos)
-		if safeTile(y, x) == 1 {
+		if solidTile(y, x) {
 			return false
 		}
 
@@ -134,47 +123,32 @@ func checkCollision(first, second pos) bool {
 	return false // No collision
 }
 
-func checkZoom() {
-	_, my := ebiten.Wheel()
-
-	if my < 0 {
-		for i := 0; i < 4 && game.camera.zoom > 0.5; i++ {
-			time.Sleep(30 * time.Millisecond)
-			game.camera.zoom -= 0.02
-		}
-	} else if my > 0 {
-		for i := 0; i < 4 && game.camera.zoom < 2.5; i++ {
-			time.Sleep(30 * time.Millisecond)
-			game.camera.zoom += 0.02
-		}
-	}
-}
-
 func (c *character) checkMovement() {
+	in := game.sim.input
 
 	// Handle movement based on key presses and check next tile for collisions
-	if ebiten.IsKeyPressed(ebiten.KeyD) && c.checkNextTile(2) { // Move right
+	if in.held(btnRight) && c.checkNextTile(2) { // Move right
 		c.pos.float_x += c.speed * float32(game.deltatime)
 		c.running = true
 
 	}
-	if ebiten.IsKeyPressed(ebiten.KeyA) && c.checkNextTile(3) { // Move left
+	if in.held(btnLeft) && c.checkNextTile(3) { // Move left
 		c.pos.float_x -= c.speed * float32(game.deltatime)
 		c.running = true
 
 	}
-	if ebiten.IsKeyPressed(ebiten.KeyW) && c.checkNextTile(0) { // Move up
+	if in.held(btnUp) && c.checkNextTile(0) { // Move up
 		c.pos.float_y -= c.speed * float32(game.deltatime)
 		c.running = true
 		c.facingNorth = 1
 	}
-	if ebiten.IsKeyPressed(ebiten.KeyS) && c.checkNextTile(1) { // Move down
+	if in.held(btnDown) && c.checkNextTile(1) { // Move down
 		c.pos.float_y += c.speed * float32(game.deltatime)
 		c.running = true
 		c.facingNorth = 0
 	}
 
-	if ebiten.IsKeyPressed(ebiten.KeyShift) {
+	if in.held(btnDash) {
 		if !c.dashing && c.untilNewDash < 0 {
 			c.dashing = true
 			c.speed = DASHSPEED
@@ -190,14 +164,8 @@ func (c *character) checkMovement() {
 		c.untilNewDash = 1.5
 	}
 
-	correctedPos := createPos(c.pos.float_x+screendivisor/2, c.pos.float_y+screendivisor/2)
-	x, y := ptid(correctedPos)
 	c.untilEndOfBoost -= game.deltatime
-	if safeTile(y, x) == 3 && !c.dashing && c.speed != BOOSTSPEED {
-		c.speed = BOOSTSPEED
-		c.untilEndOfBoost = 0.5
-
-	}
+	c.applyTileEffects()
 
 	if c.untilEndOfBoost < 0 && !c.dashing {
 		c.speed = CHARSPEED
@@ -205,7 +173,7 @@ func (c *character) checkMovement() {
 
 	c.sinceAttack -= game.deltatime
 	c.attackCooldown -= game.deltatime
-	if ebiten.IsMouseButtonPressed(ebiten.MouseButton0) {
+	if in.held(btnAttack) {
 		if !c.attacking && c.attackCooldown <= 0 {
 			c.attack()
 		} else if c.attacking && c.sinceAttack < 0.15 { // queue late phase
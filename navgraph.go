@@ -0,0 +1,153 @@
+package main
+
+import (
+	"container/heap"
+	"math"
+)
+
+// navGraph is the path network of the current map in a form that's quick to
+// search: nodes by index with adjacency lists. It's built from
+// gamemap.nodes/paths the first time it's needed (see gamemap.navGraph).
+type navGraph struct {
+	nodes []node
+	// node id -> index into nodes / adj
+	index map[int]int
+	adj   [][]navEdge
+	// A* heuristic = straight line distance * heuristicScale. Map costs are
+	// normally the path lengths (scale 1); hand written costs may be
+	// shorter, the scale keeps the heuristic from overestimating then.
+	heuristicScale float32
+}
+
+type navEdge struct {
+	to   int // node index
+	cost float32
+}
+
+func buildNavGraph(nodes []node, paths []path) *navGraph {
+	g := &navGraph{
+		nodes:          nodes,
+		index:          make(map[int]int, len(nodes)),
+		adj:            make([][]navEdge, len(nodes)),
+		heuristicScale: 1,
+	}
+	for i, n := range nodes {
+		g.index[n.id] = i
+	}
+	for _, p := range paths {
+		a, okA := g.index[p.nodeA.id]
+		b, okB := g.index[p.nodeB.id]
+		if !okA || !okB || a == b {
+			continue
+		}
+		g.adj[a] = append(g.adj[a], navEdge{to: b, cost: p.cost})
+		g.adj[b] = append(g.adj[b], navEdge{to: a, cost: p.cost})
+		if d := Distance(nodes[a].pos, nodes[b].pos); d > 0 && p.cost/d < g.heuristicScale {
+			g.heuristicScale = max(p.cost/d, 0)
+		}
+	}
+	return g
+}
+
+// navGraph returns the graph of the map's path network.
+func (m *gamemap) navGraph() *navGraph {
+	if m.nav == nil {
+		m.nav = buildNavGraph(m.nodes, m.paths)
+	}
+	return m.nav
+}
+
+// findPath runs A* between two nodes and returns the positions of the
+// nodes on the way (start and goal included), nil if there's no way.
+// allowed limits the search to some nodes, nil allows all of them.
+func (g *navGraph) findPath(startID, goalID int, allowed map[int]bool) []pos {
+	start, okS := g.index[startID]
+	goal, okG := g.index[goalID]
+	if !okS || !okG {
+		return nil
+	}
+	if allowed != nil && (!allowed[startID] || !allowed[goalID]) {
+		return nil
+	}
+
+	h := func(i int) float32 {
+		return Distance(g.nodes[i].pos, g.nodes[goal].pos) * g.heuristicScale
+	}
+	costSoFar := make([]float32, len(g.nodes))
+	cameFrom := make([]int, len(g.nodes))
+	closed := make([]bool, len(g.nodes))
+	for i := range costSoFar {
+		costSoFar[i] = math.MaxFloat32
+		cameFrom[i] = -1
+	}
+	costSoFar[start] = 0
+
+	open := &navQueue{{node: start, priority: h(start)}}
+	for open.Len() > 0 {
+		cur := heap.Pop(open).(navItem).node
+		if closed[cur] {
+			continue // stale entry, a cheaper one was handled already
+		}
+		closed[cur] = true
+		if cur == goal {
+			return g.route(cameFrom, goal)
+		}
+		for _, e := range g.adj[cur] {
+			if closed[e.to] || (allowed != nil && !allowed[g.nodes[e.to].id]) {
+				continue
+			}
+			if c := costSoFar[cur] + e.cost; c < costSoFar[e.to] {
+				costSoFar[e.to] = c
+				cameFrom[e.to] = cur
+				heap.Push(open, navItem{node: e.to, priority: c + h(e.to)})
+			}
+		}
+	}
+	return nil
+}
+
+// route walks cameFrom back from goal.
+func (g *navGraph) route(cameFrom []int, goal int) []pos {
+	n := 0
+	for i := goal; i >= 0; i = cameFrom[i] {
+		n++
+	}
+	route := make([]pos, n)
+	for i := goal; i >= 0; i = cameFrom[i] {
+		n--
+		route[n] = g.nodes[i].pos
+	}
+	return route
+}
+
+// neighbours returns the ids of the nodes connected to a node.
+func (g *navGraph) neighbours(id int) []int {
+	i, ok := g.index[id]
+	if !ok {
+		return nil
+	}
+	ids := make([]int, len(g.adj[i]))
+	for k, e := range g.adj[i] {
+		ids[k] = g.nodes[e.to].id
+	}
+	return ids
+}
+
+type navItem struct {
+	node     int
+	priority float32
+}
+
+// navQueue is a min-heap of nodes to expand (container/heap).
+type navQueue []navItem
+
+func (q navQueue) Len() int           { return len(q) }
+func (q navQueue) Less(i, j int) bool { return q[i].priority < q[j].priority }
+func (q navQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+func (q *navQueue) Push(x any)        { *q = append(*q, x.(navItem)) }
+func (q *navQueue) Pop() any {
+	old := *q
+	it := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return it
+}
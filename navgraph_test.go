@@ -0,0 +1,215 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// setNavMap makes the current map a nav graph of the given nodes & paths.
+func setNavMap(nodes []node, paths []path) {
+	game.currentmap = gamemap{nodes: nodes, paths: paths}
+}
+
+// gridNavMap is an n x n grid of nodes 100px apart, each connected to its
+// right & lower neighbour, ids y*n+x.
+func gridNavMap(n int) {
+	var nodes []node
+	var paths []path
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			nodes = append(nodes, createNode(y*n+x, createPos(float32(x*100), float32(y*100))))
+		}
+	}
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			a := nodes[y*n+x]
+			if x+1 < n {
+				paths = append(paths, path{nodeA: a, nodeB: nodes[y*n+x+1], cost: 100})
+			}
+			if y+1 < n {
+				paths = append(paths, path{nodeA: a, nodeB: nodes[(y+1)*n+x], cost: 100})
+			}
+		}
+	}
+	setNavMap(nodes, paths)
+}
+
+// routeLength adds up the distances along a route.
+func routeLength(route []pos) float32 {
+	var l float32
+	for i := 1; i < len(route); i++ {
+		l += Distance(route[i-1], route[i])
+	}
+	return l
+}
+
+// smallNavMap is
+//
+//	1 --- 2 --- 3
+//	 \         /
+//	  `-- 4 --'    4 is a detour, 5 is on its own
+//	               5
+func smallNavMap() []node {
+	nodes := []node{
+		createNode(1, createPos(0, 0)),
+		createNode(2, createPos(100, 0)),
+		createNode(3, createPos(200, 0)),
+		createNode(4, createPos(100, 150)),
+		createNode(5, createPos(500, 500)),
+	}
+	paths := []path{
+		{nodeA: nodes[0], nodeB: nodes[1], cost: 100},
+		{nodeA: nodes[2], nodeB: nodes[1], cost: 100}, // given backwards
+		{nodeA: nodes[0], nodeB: nodes[3], cost: 180},
+		{nodeA: nodes[3], nodeB: nodes[2], cost: 180},
+	}
+	setNavMap(nodes, paths)
+	return nodes
+}
+
+func TestFindPathRoute(t *testing.T) {
+	n := smallNavMap()
+	want := []pos{n[0].pos, n[1].pos, n[2].pos}
+	if got := findShortestPathPositions(1, 3); !reflect.DeepEqual(got, want) {
+		t.Errorf("1 -> 3 = %v, want %v", got, want)
+	}
+	back := []pos{n[2].pos, n[1].pos, n[0].pos}
+	if got := findShortestPathPositions(3, 1); !reflect.DeepEqual(got, back) {
+		t.Errorf("3 -> 1 = %v, want %v", got, back)
+	}
+}
+
+func TestFindPathAllowed(t *testing.T) {
+	n := smallNavMap()
+	// without 2 the detour over 4 is the only way
+	allowed := map[int]bool{1: true, 3: true, 4: true}
+	want := []pos{n[0].pos, n[3].pos, n[2].pos}
+	if got := findShortestPathPositionsConstrained(1, 3, allowed); !reflect.DeepEqual(got, want) {
+		t.Errorf("1 -> 3 without 2 = %v, want %v", got, want)
+	}
+	// neither way allowed
+	if got := findShortestPathPositionsConstrained(1, 3, map[int]bool{1: true, 3: true}); got != nil {
+		t.Errorf("1 -> 3 over no allowed node = %v, want nil", got)
+	}
+	// start or goal not allowed
+	if got := findShortestPathPositionsConstrained(1, 3, map[int]bool{1: true, 2: true}); got != nil {
+		t.Errorf("goal not allowed: %v, want nil", got)
+	}
+	if got := findShortestPathPositionsConstrained(1, 3, map[int]bool{2: true, 3: true}); got != nil {
+		t.Errorf("start not allowed: %v, want nil", got)
+	}
+	if got := findShortestPathPositionsConstrained(1, 3, nil); got != nil {
+		t.Errorf("nil allowed set = %v, want nil", got)
+	}
+}
+
+func TestFindPathUnreachable(t *testing.T) {
+	smallNavMap()
+	if got := findShortestPathPositions(1, 5); got != nil {
+		t.Errorf("1 -> 5 = %v, want nil", got)
+	}
+	if got := findShortestPathPositions(1, 42); got != nil {
+		t.Errorf("1 -> missing node = %v, want nil", got)
+	}
+	if got := findShortestPathPositions(42, 1); got != nil {
+		t.Errorf("missing node -> 1 = %v, want nil", got)
+	}
+}
+
+func TestFindPathStartIsGoal(t *testing.T) {
+	n := smallNavMap()
+	want := []pos{n[0].pos}
+	if got := findShortestPathPositions(1, 1); !reflect.DeepEqual(got, want) {
+		t.Errorf("1 -> 1 = %v, want %v", got, want)
+	}
+	// a node without any path is still a route to itself
+	if got := findShortestPathPositions(5, 5); !reflect.DeepEqual(got, []pos{n[4].pos}) {
+		t.Errorf("5 -> 5 = %v", got)
+	}
+}
+
+// The new search has to find routes as cheap as the old one did.
+func TestFindPathMatchesLegacy(t *testing.T) {
+	gridNavMap(8)
+	for _, q := range [][2]int{{0, 63}, {7, 56}, {9, 54}, {20, 20}, {63, 0}} {
+		got, want := findShortestPathPositions(q[0], q[1]), legacyFindPath(q[0], q[1])
+		if len(got) == 0 || got[0] != want[0] || got[len(got)-1] != want[len(want)-1] {
+			t.Errorf("%d -> %d = %v, legacy %v", q[0], q[1], got, want)
+		}
+		if a, b := routeLength(got), routeLength(want); math.Abs(float64(a-b)) > 1e-3 {
+			t.Errorf("%d -> %d is %v long, legacy %v", q[0], q[1], a, b)
+		}
+	}
+}
+
+func BenchmarkFindPath(b *testing.B) {
+	for _, n := range []int{10, 30} {
+		goal := n*n - 1
+		b.Run(fmt.Sprintf("navGraph/%dx%d", n, n), func(b *testing.B) {
+			gridNavMap(n)
+			game.currentmap.navGraph() // built once per map, not per search
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				findShortestPathPositions(0, goal)
+			}
+		})
+		b.Run(fmt.Sprintf("legacy/%dx%d", n, n), func(b *testing.B) {
+			gridNavMap(n)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				legacyFindPath(0, goal)
+			}
+		})
+	}
+}
+
+// legacyFindPath is the search findShortestPathPositions used before the
+// nav graph, kept as the baseline of BenchmarkFindPath: every expansion
+// scans all paths and the open list is re-sorted on every push.
+func legacyFindPath(startID, goalID int) []pos {
+	type item struct {
+		nodeID   int
+		cost     float32
+		previous *item
+	}
+	pq := []item{{nodeID: startID}}
+	costSoFar := map[int]float32{startID: 0}
+
+	for len(pq) > 0 {
+		current := pq[0]
+		pq = pq[1:]
+
+		if current.nodeID == goalID {
+			var route []pos
+			for step := &current; step != nil; step = step.previous {
+				if node := findNodeByID(step.nodeID); node != nil {
+					route = append([]pos{node.pos}, route...)
+				}
+			}
+			return route
+		}
+
+		for _, p := range game.currentmap.paths {
+			var neighborID int
+			if p.nodeA.id == current.nodeID {
+				neighborID = p.nodeB.id
+			} else if p.nodeB.id == current.nodeID {
+				neighborID = p.nodeA.id
+			} else {
+				continue
+			}
+			newCost := costSoFar[current.nodeID] + p.cost
+			if oldCost, exists := costSoFar[neighborID]; !exists || newCost < oldCost {
+				costSoFar[neighborID] = newCost
+				pq = append(pq, item{nodeID: neighborID, cost: newCost, previous: &current})
+				sort.Slice(pq, func(i, j int) bool {
+					return pq[i].cost < pq[j].cost
+				})
+			}
+		}
+	}
+	return nil
+}
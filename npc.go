@@ -7,7 +7,6 @@ import (
 
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
-	"github.com/hajimehoshi/ebiten/v2/inpututil"
 	"github.com/hajimehoshi/ebiten/v2/vector"
 )
 
@@ -15,7 +14,7 @@ import (
 type npc struct {
 	pos        pos
 	texture    *ebiten.Image
-	id         int
+	id         entityID
 	dialogue   []string
 	line       int
 	talking    bool
@@ -28,37 +27,22 @@ type npc struct {
 	frameDuration float64
 }
 
-var (
-	activeNPC      *npc
-	npcSprite      *ebiten.Image // legacy default
-	npcSpriteCache = map[string]*ebiten.Image{}
-)
+var activeNPC *npc
+
+const defaultNPCSprite = "import/Characters/hamster.png"
 
 // createNPC adds an NPC to the current map with the provided dialogue lines.
-func createNPC(p pos, lines []string) { createNPCWithSprite(p, lines, "import/Characters/hamster.png") }
+func createNPC(p pos, lines []string) { createNPCWithSprite(p, lines, defaultNPCSprite) }
 
-// createNPCWithSprite allows specifying a sprite path; caches images; falls back on default if load fails.
+// createNPCWithSprite allows specifying a sprite path; falls back on the default sprite if it can't be loaded.
 func createNPCWithSprite(p pos, lines []string, spritePath string) {
 	if spritePath == "" || spritePath == "-" {
-		spritePath = "import/Characters/hamster.png"
-	}
-	img, ok := npcSpriteCache[spritePath]
-	if !ok {
-		defer func() { // recover from potential load issues silently
-			if r := recover(); r != nil {
-				img = npcSprite
-			}
-		}()
-		img = loadPNG(spritePath)
-		if img != nil {
-			npcSpriteCache[spritePath] = img
-		}
+		spritePath = defaultNPCSprite
 	}
-	if img == nil {
-		if npcSprite == nil {
-			npcSprite = loadPNG("import/Characters/hamster.png")
-		}
-		img = npcSprite
+	img, err := assets.image(spritePath)
+	if err != nil {
+		// placeholder only if the default is missing too
+		img = loadPNG(defaultNPCSprite)
 	}
 	sheetW, sheetH := img.Size()
 	var frames []*ebiten.Image
@@ -125,8 +109,7 @@ func createNPCWithSprite(p pos, lines []string, spritePath string) {
 		}
 	}
 	n := &npc{pos: p, texture: baseImg, dialogue: lines, talkRadius: 110, name: "NPC", frames: frames, frameDuration: 0.15}
-	game.currentmap.npcs = append(game.currentmap.npcs, n)
-	drawables = append(drawables, n)
+	game.entities.add(n)
 }
 
 // draw implements drawable.
@@ -168,46 +151,43 @@ func (n *npc) update(dt float64) {
 
 // update all NPC animations
 func updateNPCAnimations(dt float64) {
-	for _, n := range game.currentmap.npcs {
+	for _, n := range game.entities.npcs {
 		n.update(dt)
 	}
 }
 
 // Y depth ordering (similar to enemy offset)
-func (n *npc) Y() float32    { return n.pos.float_y }
-func (n *npc) giveId(id int) { n.id = id }
+func (n *npc) Y() float32 { return n.pos.float_y }
 
 // updateNPCInteractions handles starting and advancing conversations.
 func updateNPCInteractions() {
-	if len(game.currentmap.players) == 0 {
+	if len(game.entities.players) == 0 {
 		return
 	}
-	player := game.currentmap.players[0]
+	player := game.entities.players[0]
 
 	// If already talking
 	if activeNPC != nil {
 		// Advance dialogue
-		if inpututil.IsKeyJustPressed(ebiten.KeySpace) || inpututil.IsKeyJustPressed(ebiten.KeyEnter) || inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+		if game.sim.justPressed(btnAdvance) || game.sim.justPressed(btnAttack) {
 			activeNPC.line++
 			if activeNPC.line >= len(activeNPC.dialogue) {
-				// End conversation
-				activeNPC.talking = false
-				activeNPC = nil
+				endConversation(true)
+				return
 			}
 		}
 		// Cancel with Escape
-		if inpututil.IsKeyJustPressed(ebiten.KeyEscape) || inpututil.IsKeyJustPressed(ebiten.KeyE) {
-			activeNPC.talking = false
-			activeNPC = nil
+		if game.sim.justPressed(btnCancel) || game.sim.justPressed(btnInteract) {
+			endConversation(false)
 		}
 		return
 	}
 
 	// Not in conversation: check for nearby NPC and E press
-	if inpututil.IsKeyJustPressed(ebiten.KeyE) {
+	if game.sim.justPressed(btnInteract) {
 		var closest *npc
 		var closestDist float32 = 1e9
-		for _, n := range game.currentmap.npcs {
+		for _, n := range game.entities.npcs {
 			d := Distance(player.pos, n.pos)
 			if d < n.talkRadius && d < closestDist {
 				closestDist = d
@@ -218,10 +198,19 @@ func updateNPCInteractions() {
 			activeNPC = closest
 			activeNPC.talking = true
 			activeNPC.line = 0
+			game.events.dialogueStarted.publish(DialogueStarted{NPC: closest, Player: player})
 		}
 	}
 }
 
+// endConversation closes the active conversation.
+func endConversation(finished bool) {
+	n := activeNPC
+	n.talking = false
+	activeNPC = nil
+	game.events.dialogueEnded.publish(DialogueEnded{NPC: n, Finished: finished})
+}
+
 // drawConversationUI renders the active conversation box.
 func drawConversationUI(screen *ebiten.Image) {
 	if activeNPC == nil {
@@ -278,3 +267,16 @@ func wrapText(s string, max int) []string {
 	}
 	return lines
 }
+
+// dialogueScene overlays the active conversation on the world. The world keeps
+// running underneath, conversation input is handled by the simulation.
+type dialogueScene struct{}
+
+func (d *dialogueScene) Enter()                  {}
+func (d *dialogueScene) Exit()                   {}
+func (d *dialogueScene) updatesBelow() bool      { return true }
+func (d *dialogueScene) Update(dt float64) error { return nil }
+
+func (d *dialogueScene) Draw(screen *ebiten.Image) {
+	drawConversationUI(screen)
+}
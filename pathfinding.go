@@ -3,8 +3,6 @@ package main
 import (
 	"fmt"
 	"math"
-	"math/rand"
-	"sort"
 
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
@@ -22,9 +20,9 @@ type path struct {
 }
 
 func findNodeByID(id int) *node {
-	for _, node := range game.currentmap.nodes {
-		if node.id == id {
-			return &node
+	for i := range game.currentmap.nodes {
+		if game.currentmap.nodes[i].id == id {
+			return &game.currentmap.nodes[i]
 		}
 	}
 	fmt.Printf("Warning: Node with ID %d not found\n", id)
@@ -133,17 +131,7 @@ func nodesWithinRange(startNode node, maxHops int) map[int]bool {
 		visited[current.nodeID] = true
 
 		// Enqueue neighbors
-		for _, p := range game.currentmap.paths {
-			// Check if the current node is part of the path
-			var neighborID int
-			if p.nodeA.id == current.nodeID {
-				neighborID = p.nodeB.id
-			} else if p.nodeB.id == current.nodeID {
-				neighborID = p.nodeA.id
-			} else {
-				continue
-			}
-
+		for _, neighborID := range game.currentmap.navGraph().neighbours(current.nodeID) {
 			if !visited[neighborID] {
 				queue = append(queue, struct {
 					nodeID int
@@ -174,22 +162,17 @@ func randomPointWithinRange(startNode node, maxHops int) pos {
 	}
 
 	// Select a random path
-	randomPath := validPaths[rand.Intn(len(validPaths))]
+	rnd := game.rng.stream(rngAI)
+	randomPath := validPaths[rnd.Intn(len(validPaths))]
 
 	// Generate a random point on the selected path
-	t := rand.Float32()
+	t := rnd.Float32()
 	x := randomPath.nodeA.pos.float_x + t*(randomPath.nodeB.pos.float_x-randomPath.nodeA.pos.float_x)
 	y := randomPath.nodeA.pos.float_y + t*(randomPath.nodeB.pos.float_y-randomPath.nodeA.pos.float_y)
 
 	return pos{float_x: x, float_y: y}
 }
 
-type priorityQueueItem struct {
-	nodeID   int
-	cost     float32
-	previous *priorityQueueItem
-}
-
 // nodesWithinCircle returns node IDs whose positions lie within radius of center
 func nodesWithinCircle(center pos, radius float32) map[int]bool {
 	allowed := make(map[int]bool)
@@ -223,124 +206,14 @@ func findClosestAllowedNodeID(target pos, allowed map[int]bool) int {
 
 // findShortestPathPositionsConstrained limits traversal to allowed node IDs
 func findShortestPathPositionsConstrained(startID, goalID int, allowed map[int]bool) []pos {
-	if !allowed[startID] || !allowed[goalID] {
+	if allowed == nil {
 		return nil
 	}
-	pq := []priorityQueueItem{{nodeID: startID, cost: 0, previous: nil}}
-	costSoFar := make(map[int]float32)
-	costSoFar[startID] = 0
-	visited := make(map[int]bool)
-
-	// To reconstruct path
-	prev := make(map[int]*priorityQueueItem)
-	prev[startID] = &pq[0]
-
-	for len(pq) > 0 {
-		cur := pq[0]
-		pq = pq[1:]
-		if visited[cur.nodeID] {
-			continue
-		}
-		visited[cur.nodeID] = true
-		if cur.nodeID == goalID {
-			// reconstruct
-			var path []pos
-			step := &cur
-			for step != nil {
-				node := findNodeByID(step.nodeID)
-				if node != nil {
-					path = append([]pos{node.pos}, path...)
-				}
-				step = step.previous
-			}
-			return path
-		}
-		for _, p := range game.currentmap.paths {
-			var neighborID int
-			if p.nodeA.id == cur.nodeID {
-				neighborID = p.nodeB.id
-			} else if p.nodeB.id == cur.nodeID {
-				neighborID = p.nodeA.id
-			} else {
-				continue
-			}
-			if !allowed[neighborID] {
-				continue
-			}
-			newCost := costSoFar[cur.nodeID] + p.cost
-			if old, ok := costSoFar[neighborID]; !ok || newCost < old {
-				costSoFar[neighborID] = newCost
-				nxt := priorityQueueItem{nodeID: neighborID, cost: newCost, previous: &cur}
-				pq = append(pq, nxt)
-				sort.Slice(pq, func(i, j int) bool { return pq[i].cost < pq[j].cost })
-			}
-		}
-	}
-	return nil
+	return game.currentmap.navGraph().findPath(startID, goalID, allowed)
 }
 
+// findShortestPathPositions returns the node positions of the cheapest way
+// between two nodes, nil if there is none. See navgraph.go.
 func findShortestPathPositions(startID, goalID int) []pos {
-	// Priority queue for exploring nodes, ordered by cost
-	pq := []priorityQueueItem{
-		{nodeID: startID, cost: 0, previous: nil},
-	}
-
-	// Map to store the shortest cost to reach each node
-	costSoFar := make(map[int]float32)
-	costSoFar[startID] = 0
-
-	// Map to reconstruct the path
-	previousNode := make(map[int]int)
-
-	for len(pq) > 0 {
-		// Extract the node with the lowest cost
-		current := pq[0]
-		pq = pq[1:]
-
-		// If we reached the goal, reconstruct the path as positions
-		if current.nodeID == goalID {
-			var path []pos
-			for step := &current; step != nil; step = step.previous {
-				node := findNodeByID(step.nodeID)
-				if node != nil {
-					path = append([]pos{node.pos}, path...)
-				}
-			}
-			return path
-		}
-
-		// Explore neighbors
-		for _, p := range game.currentmap.paths {
-			var neighborID int
-			if p.nodeA.id == current.nodeID {
-				neighborID = p.nodeB.id
-			} else if p.nodeB.id == current.nodeID {
-				neighborID = p.nodeA.id
-			} else {
-				continue
-			}
-
-			// Calculate new cost
-			newCost := costSoFar[current.nodeID] + p.cost
-			if oldCost, exists := costSoFar[neighborID]; !exists || newCost < oldCost {
-				costSoFar[neighborID] = newCost
-				previousNode[neighborID] = current.nodeID
-
-				// Add to priority queue
-				pq = append(pq, priorityQueueItem{
-					nodeID:   neighborID,
-					cost:     newCost,
-					previous: &current,
-				})
-
-				// Sort the priority queue by cost
-				sort.Slice(pq, func(i, j int) bool {
-					return pq[i].cost < pq[j].cost
-				})
-			}
-		}
-	}
-
-	// If no path is found, return an empty slice
-	return nil
+	return game.currentmap.navGraph().findPath(startID, goalID, nil)
 }
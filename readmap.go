@@ -6,10 +6,11 @@ import (
 	"os"
 	"strconv"
 	"strings"
+
+	"rpg/mapio"
 )
 
-func readMapData() {
-	filename := "map.txt"
+func readMapData(filename string) {
 
 	file, err := os.Open(filename)
 	if err != nil {
@@ -28,7 +29,8 @@ func readMapData() {
 
 	}
 
-	// Clear existing data
+	// the legacy maps are 100x100
+	md := mapio.NewMapData(100, 100)
 
 	y := 0
 
@@ -198,7 +200,7 @@ func readMapData() {
 					return
 				}
 
-				game.currentmap.data[y][x] = intValue
+				md.SetTile(x, y, intValue)
 			}
 			y++
 		}
@@ -210,7 +212,8 @@ func readMapData() {
 		return
 	}
 
-	game.currentmap.height, game.currentmap.width = 100, 100
+	game.currentmap.height, game.currentmap.width = md.Height, md.Width
+	game.currentmap.chunks = createChunkCache(mapio.NewMemorySource(md, mapio.DefaultChunkSize))
 
 	fmt.Println("File read successfully!")
 }
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+)
+
+const replayVersion = 1
+
+// replayFrame is an input change at a given simulation tick. Only ticks where
+// the held buttons change (or the wheel moved) are stored.
+type replayFrame struct {
+	Tick    int     `json:"tick"`
+	Buttons uint16  `json:"buttons"`
+	Wheel   float64 `json:"wheel,omitempty"`
+}
+
+// replayExpect is the world state at the end of a replay, checked by -check.
+type replayExpect struct {
+	PlayerHP float32 `json:"player_hp"`
+	PlayerX  float32 `json:"player_x"`
+	PlayerY  float32 `json:"player_y"`
+	Enemies  int     `json:"enemies"`
+}
+
+// replayFile is the on-disk (JSON) replay: everything needed to start the
+// same world (seed, map, player start) plus the per-tick input stream.
+type replayFile struct {
+	Version int           `json:"version"`
+	Seed    int64         `json:"seed"`
+	Map     string        `json:"map"`
+	StartX  float32       `json:"start_x"`
+	StartY  float32       `json:"start_y"`
+	Ticks   int           `json:"ticks"`
+	Frames  []replayFrame `json:"frames"`
+	Expect  *replayExpect `json:"expect,omitempty"`
+}
+
+func loadReplay(path string) (*replayFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rf replayFile
+	if err := json.Unmarshal(data, &rf); err != nil {
+		return nil, fmt.Errorf("parse replay %s: %v", path, err)
+	}
+	if rf.Version != replayVersion {
+		return nil, fmt.Errorf("replay %s: unsupported version %d", path, rf.Version)
+	}
+	return &rf, nil
+}
+
+// currentExpect captures the state replays assert on.
+func currentExpect() *replayExpect {
+	ex := &replayExpect{Enemies: len(game.entities.enemies)}
+	if len(game.entities.players) > 0 {
+		p := game.entities.players[0]
+		ex.PlayerHP = p.hp
+		ex.PlayerX = p.pos.float_x
+		ex.PlayerY = p.pos.float_y
+	}
+	return ex
+}
+
+// replayRecorder collects the input used by every simulation step.
+type replayRecorder struct {
+	path  string
+	file  replayFile
+	last  inputButton
+	ticks int
+}
+
+func newReplayRecorder(path string, seed int64, mapPath string, start pos) *replayRecorder {
+	return &replayRecorder{
+		path: path,
+		file: replayFile{
+			Version: replayVersion,
+			Seed:    seed,
+			Map:     mapPath,
+			StartX:  start.float_x,
+			StartY:  start.float_y,
+		},
+	}
+}
+
+func (r *replayRecorder) record(tick int, in inputState) {
+	if tick == 0 || in.buttons != r.last || in.wheel != 0 {
+		r.file.Frames = append(r.file.Frames, replayFrame{Tick: tick, Buttons: uint16(in.buttons), Wheel: in.wheel})
+		r.last = in.buttons
+	}
+	r.ticks = tick + 1
+}
+
+// save writes the replay along with the current state as its expected outcome.
+func (r *replayRecorder) save() error {
+	r.file.Ticks = r.ticks
+	r.file.Expect = currentExpect()
+	data, err := json.MarshalIndent(r.file, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(r.path, data, 0o644); err != nil {
+		return err
+	}
+	fmt.Printf("replay saved: %s (%d ticks)\n", r.path, r.ticks)
+	return nil
+}
+
+// replayPlayer feeds recorded input back into the simulation.
+type replayPlayer struct {
+	file *replayFile
+	next int
+	cur  inputButton
+}
+
+func newReplayPlayer(rf *replayFile) *replayPlayer {
+	return &replayPlayer{file: rf}
+}
+
+// inputFor returns the recorded input for a tick. Ticks are consumed in order.
+func (p *replayPlayer) inputFor(tick int) inputState {
+	var in inputState
+	for p.next < len(p.file.Frames) && p.file.Frames[p.next].Tick <= tick {
+		f := p.file.Frames[p.next]
+		p.cur = inputButton(f.Buttons)
+		if f.Tick == tick {
+			in.wheel = f.Wheel
+		}
+		p.next++
+	}
+	in.buttons = p.cur
+	return in
+}
+
+func (p *replayPlayer) done(tick int) bool {
+	return tick >= p.file.Ticks
+}
+
+// check compares the current world against the replay's expected outcome.
+// Returns nil when there is nothing to check.
+func (p *replayPlayer) check() error {
+	ex := p.file.Expect
+	if ex == nil {
+		return nil
+	}
+	got := currentExpect()
+	const eps = 0.01
+	near := func(a, b float32) bool { return math.Abs(float64(a-b)) <= eps }
+	if !near(got.PlayerHP, ex.PlayerHP) {
+		return fmt.Errorf("player hp %.2f, expected %.2f", got.PlayerHP, ex.PlayerHP)
+	}
+	if !near(got.PlayerX, ex.PlayerX) || !near(got.PlayerY, ex.PlayerY) {
+		return fmt.Errorf("player pos (%.2f, %.2f), expected (%.2f, %.2f)", got.PlayerX, got.PlayerY, ex.PlayerX, ex.PlayerY)
+	}
+	if got.Enemies != ex.Enemies {
+		return fmt.Errorf("%d enemies alive, expected %d", got.Enemies, ex.Enemies)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// playReplay runs a replay headlessly like `-replay path -check` does.
+func playReplay(t *testing.T, rf *replayFile) {
+	t.Helper()
+	mapPath := rf.Map
+	if mapPath == "" {
+		mapPath = "map.txt"
+	}
+	p := startWorld(t, rf.Seed, mapPath)
+	p.teleport(createPos(rf.StartX, rf.StartY))
+	game.replay = newReplayPlayer(rf)
+	for i := 0; i < rf.Ticks; i++ {
+		// the replay takes over input inside Step
+		game.sim.setInput(inputState{})
+		game.sim.Step(simDT)
+	}
+}
+
+func TestReplays(t *testing.T) {
+	paths, err := filepath.Glob("testdata/replays/*.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatal("no replays in testdata/replays")
+	}
+	for _, path := range paths {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			rf, err := loadReplay(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rf.Expect == nil {
+				t.Fatal("replay has no expect block")
+			}
+			playReplay(t, rf)
+			if game.sim.tick != rf.Ticks {
+				t.Errorf("ran %d ticks, replay has %d", game.sim.tick, rf.Ticks)
+			}
+			if err := game.replay.check(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+// check has to notice when the world ends up somewhere else.
+func TestReplayCheckMismatch(t *testing.T) {
+	rf, err := loadReplay("testdata/replays/walk.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	playReplay(t, rf)
+	want := *rf.Expect
+	tests := []struct {
+		name   string
+		change func(ex *replayExpect)
+	}{
+		{"hp", func(ex *replayExpect) { ex.PlayerHP -= 1 }},
+		{"x", func(ex *replayExpect) { ex.PlayerX += 5 }},
+		{"y", func(ex *replayExpect) { ex.PlayerY -= 5 }},
+		{"enemies", func(ex *replayExpect) { ex.Enemies++ }},
+	}
+	for _, tt := range tests {
+		ex := want
+		tt.change(&ex)
+		rf.Expect = &ex
+		if err := game.replay.check(); err == nil {
+			t.Errorf("%s: check passed with %+v, world is %+v", tt.name, ex, *currentExpect())
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+)
+
+// Random streams, one per subsystem. Keeping them apart means that e.g. an
+// extra damage-number jitter roll doesn't shift the next crit or spawn roll.
+const (
+	rngCombat = "combat" // damage & crit rolls
+	rngSpawn  = "spawn"  // spawner timing & spawn positions
+	rngAI     = "ai"     // patrol goals
+	rngWorld  = "world"  // tree variants, grass textures, map generation
+	rngFX     = "fx"     // purely visual randomness (damage numbers, animation offsets)
+)
+
+// rngService hands out seeded random streams. The same seed always yields
+// the same sequence per stream, regardless of the order streams are first used.
+type rngService struct {
+	seed    int64
+	streams map[string]*rand.Rand
+}
+
+func newRNG(seed int64) *rngService {
+	return &rngService{seed: seed, streams: make(map[string]*rand.Rand)}
+}
+
+// stream returns the generator for a subsystem, creating it on first use.
+func (r *rngService) stream(name string) *rand.Rand {
+	if s, ok := r.streams[name]; ok {
+		return s
+	}
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	s := rand.New(rand.NewSource(r.seed ^ int64(h.Sum64())))
+	r.streams[name] = s
+	return s
+}
+
+// at returns a generator for one spot of the world, e.g. a map chunk. It
+// doesn't advance any stream, so a spot rolls the same numbers however
+// often (and whenever) it's visited.
+func (r *rngService) at(name string, x, y int) *rand.Rand {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%s/%d/%d", name, x, y)
+	return rand.New(rand.NewSource(r.seed ^ int64(h.Sum64())))
+}
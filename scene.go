@@ -0,0 +1,125 @@
+package main
+
+import (
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// scene is one screen of the game (menu, world, pause...).
+//
+// Scenes live on a stack. Only the top one reacts to input; scenes below it
+// keep being drawn while the top is an overlay.
+type scene interface {
+	// Enter is called when the scene is pushed, Exit when it is popped.
+	Enter()
+	Exit()
+	Update(dt float64) error
+	Draw(screen *ebiten.Image)
+}
+
+// overlayScene is implemented by scenes drawn on top of the scene below them
+// (pause, dialogue, inventory...).
+type overlayScene interface {
+	scene
+	// updatesBelow reports whether the scene underneath keeps updating, e.g. the
+	// world keeps running under a dialogue box but not under the pause menu.
+	updatesBelow() bool
+}
+
+// musicScene is implemented by scenes that change the music volume while on
+// the stack (1 = normal volume).
+type musicScene interface {
+	musicScale() float64
+}
+
+type sceneStack struct {
+	scenes []scene
+}
+
+// push puts s on top of the stack.
+func (st *sceneStack) push(s scene) {
+	st.scenes = append(st.scenes, s)
+	s.Enter()
+}
+
+// pop removes the top scene.
+func (st *sceneStack) pop() {
+	if len(st.scenes) == 0 {
+		return
+	}
+	top := st.scenes[len(st.scenes)-1]
+	st.scenes = st.scenes[:len(st.scenes)-1]
+	top.Exit()
+}
+
+// popScene removes s and everything above it, if s is on the stack.
+func (st *sceneStack) popScene(s scene) {
+	for i := len(st.scenes) - 1; i >= 0; i-- {
+		if st.scenes[i] == s {
+			for len(st.scenes) > i {
+				st.pop()
+			}
+			return
+		}
+	}
+}
+
+// reset clears the stack and starts over with s.
+func (st *sceneStack) reset(s scene) {
+	for len(st.scenes) > 0 {
+		st.pop()
+	}
+	st.push(s)
+}
+
+func (st *sceneStack) top() scene {
+	if len(st.scenes) == 0 {
+		return nil
+	}
+	return st.scenes[len(st.scenes)-1]
+}
+
+// Update updates the top scene and, through overlays that allow it, the
+// scenes below. Lower scenes update first so overlays see the fresh state.
+func (st *sceneStack) Update(dt float64) error {
+	first := len(st.scenes) - 1
+	for first > 0 {
+		o, ok := st.scenes[first].(overlayScene)
+		if !ok || !o.updatesBelow() {
+			break
+		}
+		first--
+	}
+	// scenes may push/pop while updating, work on a snapshot
+	active := append([]scene(nil), st.scenes[max(first, 0):]...)
+	for _, s := range active {
+		if err := s.Update(dt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Draw draws from the topmost opaque scene upwards.
+func (st *sceneStack) Draw(screen *ebiten.Image) {
+	first := len(st.scenes) - 1
+	for first > 0 {
+		if _, ok := st.scenes[first].(overlayScene); !ok {
+			break
+		}
+		first--
+	}
+	for i := max(first, 0); i < len(st.scenes); i++ {
+		st.scenes[i].Draw(screen)
+	}
+}
+
+// musicScale combines the volume scale of every scene on the stack.
+func (st *sceneStack) musicScale() float64 {
+	scale := 1.0
+	for _, s := range st.scenes {
+		if m, ok := s.(musicScene); ok {
+			scale *= m.musicScale()
+		}
+	}
+	return scale
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// simulation steps per second, independent of the render rate
+	simTickRate = 60
+	simDT       = 1.0 / simTickRate
+	// upper bound of catch-up steps per Update so a long stall (window drag,
+	// breakpoint) doesn't turn into a spiral of ever longer updates
+	maxStepsPerUpdate = 8
+)
+
+// simulation owns the gameplay update order.
+//
+// It never touches the screen: Game.Update feeds it polled input and calls
+// Step, while Draw only renders whatever state Step left behind. The same
+// Step can be driven without a window (see runHeadless).
+type simulation struct {
+	// number of steps taken since the world was created
+	tick int
+	// total simulated time in seconds
+	elapsed float64
+
+	input     inputState
+	prevInput inputState
+
+	// real time not yet consumed by fixed steps
+	accumulator float64
+	// when advance last ran, used for the render interpolation alpha
+	lastAdvance time.Time
+}
+
+// setInput stores the controls used by the next Step.
+func (s *simulation) setInput(in inputState) {
+	s.input = in
+}
+
+// justPressed reports whether b went down between the previous and the current step.
+func (s *simulation) justPressed(b inputButton) bool {
+	return s.input.held(b) && !s.prevInput.held(b)
+}
+
+// advance consumes realDT seconds of wall clock time in fixed simDT steps.
+// Input polled once per Update is shared by all steps of that Update; one-shot
+// values (wheel) only apply to the first of them.
+func (s *simulation) advance(realDT float64) {
+	s.lastAdvance = time.Now()
+	s.accumulator += realDT
+	if s.accumulator > simDT*maxStepsPerUpdate {
+		s.accumulator = simDT * maxStepsPerUpdate
+	}
+	for s.accumulator >= simDT {
+		s.Step(simDT)
+		s.accumulator -= simDT
+		s.input.wheel = 0
+	}
+}
+
+// alpha is how far (0..1) rendering is between the previous and the current
+// simulation step. Draw uses it to interpolate positions.
+func (s *simulation) alpha() float32 {
+	if s.lastAdvance.IsZero() {
+		return 1
+	}
+	a := (s.accumulator + time.Since(s.lastAdvance).Seconds()) / simDT
+	if a < 0 {
+		return 0
+	}
+	if a > 1 {
+		return 1
+	}
+	return float32(a)
+}
+
+// Step advances players, enemies, NPCs, spawners and damage indicators by dt seconds.
+func (s *simulation) Step(dt float64) {
+	game.deltatime = dt
+
+	// A replay overrides live input; a recorder stores whatever gets used
+	if game.replay != nil && !game.replay.done(s.tick) {
+		s.input = game.replay.inputFor(s.tick)
+	}
+	if game.recorder != nil {
+		game.recorder.record(s.tick, s.input)
+	}
+	// the players can't act while the screen fades to another map
+	if inTransition() {
+		s.input = inputState{}
+	}
+	if s.input.wheel != 0 {
+		game.camera.zoomBy(s.input.wheel)
+	}
+
+	// Remember where everything was so Draw can interpolate
+	game.camera.prevPos = game.camera.pos
+	for _, c := range game.entities.players {
+		c.prevPos = c.pos
+	}
+	for _, e := range game.entities.enemies {
+		e.prevPos = e.pos
+	}
+
+	// Iterate over copies, entities may remove themselves (death) or be
+	// added (spawners) while we're walking the lists.
+	players := append([]*character(nil), game.entities.players...)
+	for _, c := range players {
+		c.todoCharacter()
+	}
+	enemies := append([]*enemy(nil), game.entities.enemies...)
+	for _, e := range enemies {
+		e.todoEnemy()
+	}
+
+	// after the players moved, so the view doesn't lag a step behind
+	updateCamera(dt)
+	streamChunks()
+
+	updatePortals(dt)
+	updateNPCInteractions()
+	updateNPCAnimations(dt)
+	updateSpawners(dt)
+	updateDamageIndicators(dt)
+
+	// apply deaths & removals from this step
+	game.entities.flush()
+	game.currentmap.dropFlowFields()
+
+	s.prevInput = s.input
+	s.tick++
+	s.elapsed += dt
+}
+
+// runHeadless steps the world for the given number of ticks with no window,
+// no audio and no live input, then prints a short summary.
+func runHeadless(ticks int) {
+	for i := 0; i < ticks; i++ {
+		// replays (if any) take over input inside Step
+		game.sim.setInput(inputState{})
+		game.sim.Step(simDT)
+	}
+
+	fmt.Printf("headless: %d ticks (%.2fs simulated)\n", game.sim.tick, game.sim.elapsed)
+	for i, c := range game.entities.players {
+		fmt.Printf("  player %d: hp=%.1f pos=(%.1f, %.1f)\n", i, c.hp, c.pos.float_x, c.pos.float_y)
+	}
+	fmt.Printf("  enemies alive: %d\n", len(game.entities.enemies))
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+// startWorld builds the world the way main does for a headless run and
+// returns the player.
+func startWorld(t testing.TB, seed int64, mapPath string) *character {
+	t.Helper()
+	game = Game{}
+	game.rng = newRNG(seed)
+	headless = true
+	cfg.mapPath = mapPath
+	gameinit()
+	if len(game.entities.players) == 0 {
+		t.Fatal("gameinit left no player")
+	}
+	return game.entities.players[0]
+}
+
+// walkInput is a fixed script: right for a second, down for half a second,
+// then standing still.
+func walkInput(tick int) inputState {
+	switch {
+	case tick < 60:
+		return inputState{buttons: btnRight}
+	case tick < 90:
+		return inputState{buttons: btnDown}
+	}
+	return inputState{}
+}
+
+func stepWorld(ticks int, input func(tick int) inputState) {
+	for i := 0; i < ticks; i++ {
+		game.sim.setInput(input(i))
+		game.sim.Step(simDT)
+	}
+}
+
+func TestSimulationStep(t *testing.T) {
+	// flat plains, the default enemies spawn far from the walk
+	p := startWorld(t, 1, "testdata/flat.txt")
+	p.teleport(createPos(1000, 100))
+	stepWorld(120, walkInput)
+
+	if game.sim.tick != 120 {
+		t.Errorf("tick = %d, want 120", game.sim.tick)
+	}
+	if math.Abs(game.sim.elapsed-2) > 1e-9 {
+		t.Errorf("elapsed = %v, want 2s", game.sim.elapsed)
+	}
+	if len(game.entities.players) != 1 {
+		t.Fatalf("%d players, want 1", len(game.entities.players))
+	}
+	// CHARSPEED px/s: 1s right, 0.5s down
+	near := func(a, b float32) bool { return math.Abs(float64(a-b)) < 0.01 }
+	if !near(p.pos.float_x, 1260) || !near(p.pos.float_y, 230) {
+		t.Errorf("player at (%v, %v), want (1260, 230)", p.pos.float_x, p.pos.float_y)
+	}
+	if p.hp != 100 {
+		t.Errorf("player hp = %v, want 100", p.hp)
+	}
+}
+
+// worldState is what two runs with the same seed & input have to agree on.
+type worldState struct {
+	tick    int
+	elapsed float64
+	players []float32 // x, y, hp per player
+	enemies []float32 // x, y, hp per enemy
+}
+
+func snapshotWorld() worldState {
+	s := worldState{tick: game.sim.tick, elapsed: game.sim.elapsed}
+	for _, c := range game.entities.players {
+		s.players = append(s.players, c.pos.float_x, c.pos.float_y, c.hp)
+	}
+	for _, e := range game.entities.enemies {
+		s.enemies = append(s.enemies, e.pos.float_x, e.pos.float_y, e.hp)
+	}
+	return s
+}
+
+func TestSimulationDeterministic(t *testing.T) {
+	// the real map: spawners, patrols & combat all draw from the seed
+	input := func(tick int) inputState {
+		in := walkInput(tick % 240)
+		if tick%40 < 5 {
+			in.buttons |= btnAttack
+		}
+		return in
+	}
+	run := func(seed int64) worldState {
+		startWorld(t, seed, "map.txt")
+		stepWorld(600, input)
+		return snapshotWorld()
+	}
+
+	a, b := run(7), run(7)
+	if a.tick != 600 || math.Abs(a.elapsed-10) > 1e-9 {
+		t.Errorf("after 600 steps: tick %d, %vs simulated", a.tick, a.elapsed)
+	}
+	if !reflect.DeepEqual(a, b) {
+		t.Errorf("same seed, different worlds:\n%+v\n%+v", a, b)
+	}
+}
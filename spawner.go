@@ -2,9 +2,7 @@ package main
 
 import (
 	"math"
-	"math/rand"
 	"rpg/mapio"
-	"time"
 )
 
 // runtimeSpawner augments mapio.EnemySpawner with timing & tracking
@@ -19,9 +17,10 @@ var spawners []*runtimeSpawner
 
 func initSpawners(m *mapio.MapData) {
 	spawners = []*runtimeSpawner{}
+	rnd := game.rng.stream(rngSpawn)
 	for _, sp := range m.Spawners {
 		rs := &runtimeSpawner{data: sp, alive: make(map[*enemy]struct{})}
-		rs.nextJitter = rand.Float64() * float64(sp.IntervalSeconds)
+		rs.nextJitter = rnd.Float64() * float64(sp.IntervalSeconds)
 		spawners = append(spawners, rs)
 	}
 }
@@ -52,9 +51,10 @@ func updateSpawners(dt float64) {
 
 func spawnEnemyFromSpawner(index int, rs *runtimeSpawner) {
 	// Random point within circle (uniform)
-	u := rand.Float64()
+	rnd := game.rng.stream(rngSpawn)
+	u := rnd.Float64()
 	r := math.Sqrt(u) * float64(rs.data.Radius)
-	theta := rand.Float64() * math.Pi * 2
+	theta := rnd.Float64() * math.Pi * 2
 	x := rs.data.Pos.X + float32(r*math.Cos(theta))
 	y := rs.data.Pos.Y + float32(r*math.Sin(theta))
 	epos := createPos(x, y)
@@ -66,12 +66,7 @@ func spawnEnemyFromSpawner(index int, rs *runtimeSpawner) {
 			epos = closest
 		}
 	}
-	createEnemy(epos)
-	e := game.currentmap.enemies[len(game.currentmap.enemies)-1]
-	e.homePos = createPos(rs.data.Pos.X, rs.data.Pos.Y)
-	e.leashRadius = rs.data.Radius
-	e.spawnerIndex = index
-	rs.alive[e] = struct{}{}
+	createEnemy(epos, index)
 }
 
 func removeEnemyFromSpawner(e *enemy) {
@@ -81,7 +76,3 @@ func removeEnemyFromSpawner(e *enemy) {
 	rs := spawners[e.spawnerIndex]
 	delete(rs.alive, e)
 }
-
-func init() {
-	rand.Seed(time.Now().UnixNano())
-}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"math"
+)
+
+// Enemy steering. Every enemy move is a blend of
+//   - seek: head for the target at full speed (arrive: slow down near it and
+//     stop a bit short, used for the player so a pack stands around them)
+//   - separation: get away from enemies that are too close
+//
+// and is then turned away from solid tiles (obstacle avoidance) before it's
+// applied. Without separation a pack from one spawner ends up on a single
+// point, the player's position.
+const (
+	// enemies closer than this push each other apart (px, a body is 30)
+	SEPARATION_RADIUS = 40
+	// how much separation counts against seek (seek is 1 at full speed)
+	SEPARATION_WEIGHT = 1.2
+	// arrive slows down inside this distance of the target...
+	ARRIVE_RADIUS = 60
+	// ...and stops this far from it (inside the 50px hit range)
+	ARRIVE_STOP_DISTANCE = 28
+	// how far ahead obstacle avoidance checks for solid tiles (px)
+	AVOID_LOOKAHEAD = 20
+)
+
+// avoidAngles are the turns (radians) tried when the way ahead is blocked,
+// straight on first.
+var avoidAngles = []float64{0, math.Pi / 6, -math.Pi / 6, math.Pi / 3, -math.Pi / 3, math.Pi / 2, -math.Pi / 2}
+
+// seek steers towards target at full speed.
+func (e *enemy) seek(target pos) {
+	e.steer(target, 1)
+}
+
+// arrive steers towards target, slowing down on the last ARRIVE_RADIUS and
+// stopping ARRIVE_STOP_DISTANCE short of it.
+func (e *enemy) arrive(target pos) {
+	d := Distance(e.pos, target)
+	scale := (d - ARRIVE_STOP_DISTANCE) / (ARRIVE_RADIUS - ARRIVE_STOP_DISTANCE)
+	e.steer(target, min(max(scale, 0), 1))
+}
+
+// steer moves the enemy one step towards target at speed*scale, pushed
+// apart from the others and around solid tiles.
+func (e *enemy) steer(target pos, scale float32) {
+	step := e.speed * float32(game.deltatime)
+	if step <= 0 {
+		return
+	}
+
+	// seek, no further than the target so it doesn't overshoot & jitter
+	var vx, vy float32
+	dx := target.float_x - e.pos.float_x
+	dy := target.float_y - e.pos.float_y
+	if d := float32(math.Hypot(float64(dx), float64(dy))); d > 0 {
+		s := scale * min(d/step, 1)
+		vx, vy = dx/d*s, dy/d*s
+	}
+
+	sx, sy := e.separation()
+	vx += sx * SEPARATION_WEIGHT
+	vy += sy * SEPARATION_WEIGHT
+
+	l := float32(math.Hypot(float64(vx), float64(vy)))
+	if l < 0.05 {
+		return // standing still
+	}
+	dirX, dirY := vx/l, vy/l
+	dirX, dirY, ok := e.avoidSolid(dirX, dirY, min(AVOID_LOOKAHEAD, Distance(e.pos, target)), step)
+	if !ok {
+		return
+	}
+	step *= min(l, 1)
+	e.pos.float_x += dirX * step
+	e.pos.float_y += dirY * step
+	e.animationState = 1
+}
+
+// separation returns the push away from the enemies within
+// SEPARATION_RADIUS, stronger the closer they are (length up to ~1 per
+// enemy).
+func (e *enemy) separation() (float32, float32) {
+	var sx, sy float32
+	for _, o := range game.entities.enemies {
+		if o == e || o.dead {
+			continue
+		}
+		dx := e.pos.float_x - o.pos.float_x
+		dy := e.pos.float_y - o.pos.float_y
+		d := float32(math.Hypot(float64(dx), float64(dy)))
+		if d >= SEPARATION_RADIUS {
+			continue
+		}
+		if d == 0 {
+			// right on top of each other: split along an angle picked from
+			// the ids, so the two go opposite ways (and replays stay the same)
+			a := float64(min(e.id, o.id)) * 2.39996 // golden angle
+			if e.id > o.id {
+				a += math.Pi
+			}
+			dx, dy, d = float32(math.Cos(a)), float32(math.Sin(a)), 1
+		}
+		w := 1 - d/SEPARATION_RADIUS
+		sx += dx / d * w
+		sy += dy / d * w
+	}
+	return sx, sy
+}
+
+// avoidSolid turns the direction (a unit vector) away from solid tiles: the
+// first of avoidAngles where the body stays clear for the next step and
+// lookahead px. False if every way is blocked. An enemy already overlapping
+// a wall (knocked into it) isn't held back, it has to be able to get out.
+func (e *enemy) avoidSolid(dirX, dirY, lookahead, step float32) (float32, float32, bool) {
+	if !bodyClear(e.pos) {
+		return dirX, dirY, true
+	}
+	lookahead = max(lookahead, step)
+	for _, a := range avoidAngles {
+		sin, cos := math.Sincos(a)
+		cx := dirX*float32(cos) - dirY*float32(sin)
+		cy := dirX*float32(sin) + dirY*float32(cos)
+		if bodyClear(createPos(e.pos.float_x+cx*step, e.pos.float_y+cy*step)) &&
+			bodyClear(createPos(e.pos.float_x+cx*lookahead, e.pos.float_y+cy*lookahead)) {
+			return cx, cy, true
+		}
+	}
+	return dirX, dirY, false
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+
+	"rpg/mapio"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// tile definitions from import/tiles.json, shared with the map editor
+var tileDefs *mapio.TileSet
+
+func loadTileDefs() {
+	ts, err := mapio.LoadTileSet(assetPath("import/tiles.json"))
+	if err != nil {
+		// keep going, every tile is just walkable & untextured
+		fmt.Println("Tile definitions load failed:", err)
+		ts, _ = mapio.ParseTileSet([]byte(`{"tiles":[]}`))
+	}
+	tileDefs = ts
+}
+
+// tileDefAt returns the definition of the ground tile at (y, x), nil if unknown.
+func tileDefAt(y, x int) *mapio.TileDef {
+	return tileDefs.Get(safeTile(y, x))
+}
+
+// pickVariant picks one of the def's textures by weight, "" if it has none.
+func pickVariant(def *mapio.TileDef, rnd *rand.Rand) string {
+	total := 0
+	for _, v := range def.Variants {
+		total += variantWeight(v)
+	}
+	if total == 0 {
+		return ""
+	}
+	n := rnd.Intn(total)
+	for _, v := range def.Variants {
+		n -= variantWeight(v)
+		if n < 0 {
+			return v.Texture
+		}
+	}
+	return ""
+}
+
+func variantWeight(v mapio.TileVariant) int {
+	if v.Weight <= 0 {
+		return 1
+	}
+	return v.Weight
+}
+
+// tileTexture resolves the texture of the tile at (i, j) of a layer (get
+// returns its values): autotiles look at their neighbours, everything else
+// picks a variant.
+func tileTexture(get func(y, x int) int, i, j int, rnd *rand.Rand) *ebiten.Image {
+	def := tileDefs.Get(get(i, j))
+	if def == nil {
+		return nil
+	}
+	if def.Autotile != nil {
+		same := func(y, x int) bool {
+			return get(y, x) == def.ID
+		}
+		mask := def.Autotile.Mask(same(i-1, j), same(i, j-1), same(i, j+1), same(i+1, j))
+		return loadPNG(def.Autotile.Texture(mask))
+	}
+	if path := pickVariant(def, rnd); path != "" {
+		return loadPNG(path)
+	}
+	return nil
+}
+
+// applyTileEffects handles the speed and damage of the tile under the player.
+func (c *character) applyTileEffects() {
+	center := createPos(c.pos.float_x+screendivisor/2, c.pos.float_y+screendivisor/2)
+	x, y := ptid(center)
+	def := tileDefAt(y, x)
+	if def == nil {
+		return
+	}
+
+	// the speed change lingers a bit after leaving the tile
+	if mult := def.Speed(); mult != 1 && !c.dashing && !c.attacking {
+		c.speed = CHARSPEED * mult
+		c.untilEndOfBoost = 0.5
+	}
+
+	if def.DamagePerSecond > 0 {
+		dmg := def.DamagePerSecond * float32(game.deltatime)
+		c.hp -= dmg
+		game.events.playerHurt.publish(PlayerHurt{Player: c, Amount: dmg})
+	}
+}
@@ -1,13 +1,11 @@
 package main
 
 import (
-	"math/rand"
-
 	"github.com/hajimehoshi/ebiten/v2"
 )
 
 type tree struct {
-	id int
+	id entityID
 
 	typeOf  int
 	pos     pos
@@ -21,10 +19,10 @@ func createSprite(pos pos, typeOf int) {
 	switch typeOf {
 	case 0: // tree
 		t.typeOf = 0
-		t.treeId = rand.Intn(len(trees))
-		t.texture = trees[t.treeId]
+		t.treeId = game.rng.stream(rngWorld).Intn(len(treeTexturePaths))
+		t.texture = loadPNG(treeTexturePaths[t.treeId])
 		t.pos = pos
 
-		drawables = append(drawables, &t)
+		game.entities.add(&t)
 	}
 }
@@ -23,7 +23,7 @@ func (c *character) drawUi() {
 	// Smooth HP animation (lerp)
 	target := c.hp
 	diff := target - c.uiHp
-	c.uiHp += diff * float32(math.Min(1, game.deltatime*8)) // respond quickly but smooth
+	c.uiHp += diff * float32(math.Min(1, game.frameDelta*8)) // respond quickly but smooth
 
 	// Panel background
 	// Widen panel to accommodate separate area for dash circle
@@ -99,7 +99,7 @@ func (c *character) drawUi() {
 			drawFilledCircle(screenGlobal, cx, cy, innerCoreR, color.RGBA{35, 35, 45, 255})
 		}
 	} else {
-		pulseTime += game.deltatime
+		pulseTime += game.frameDelta
 		pulse := float32(0.6 + 0.1*math.Sin(pulseTime*4))
 		col := color.RGBA{uint8(60 + 30*pulse), uint8(200 + 40*pulse), uint8(110 + 30*pulse), 255}
 		readyR := radius - ringThickness + 2
@@ -2,11 +2,8 @@ package main
 
 import (
 	"image/color"
-	"image/png"
-	"log"
 	"math"
 	"math/rand"
-	"os"
 
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
@@ -14,28 +11,17 @@ import (
 	"github.com/hajimehoshi/ebiten/v2/vector"
 )
 
-// Returns either true or false
-func calcChance(chance float64) bool {
-	flip := float64(rand.Intn(100))
+// Returns true with the given chance (0-100) using the provided stream
+func calcChance(rnd *rand.Rand, chance float64) bool {
+	flip := float64(rnd.Intn(100))
 	return flip < chance
 }
 
+// loadPNG returns the texture at path, or the "missing texture" placeholder
+// if it can't be loaded (see assets.go).
 func loadPNG(path string) *ebiten.Image {
-	// Open the image file
-	file, err := os.Open(path)
-	if err != nil {
-		log.Fatal(err)
-	}
-	defer file.Close()
-
-	// Decode the image file into an image.Image
-	imgData, err := png.Decode(file)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	// Convert the image.Image to an *ebiten.Image
-	return ebiten.NewImageFromImage(imgData)
+	img, _ := assets.image(path)
+	return img
 }
 
 // Color variable for ui
@@ -63,6 +49,14 @@ type pos struct {
 	float_x, float_y float32
 }
 
+// lerpPos interpolates between a (t = 0) and b (t = 1)
+func lerpPos(a, b pos, t float32) pos {
+	return pos{
+		float_x: a.float_x + (b.float_x-a.float_x)*t,
+		float_y: a.float_y + (b.float_y-a.float_y)*t,
+	}
+}
+
 func Distance(a, b pos) float32 {
 	dx := float64(b.float_x - a.float_x)
 	dy := float64(b.float_y - a.float_y)
@@ -104,6 +98,14 @@ func ptid(pos pos) (int, int) {
 	return x, y
 }
 
+// abs returns the absolute value of an int.
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
 // pos variables for cursor
 var (
 	curspos pos
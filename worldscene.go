@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"strconv"
+
+	"rpg/mapio"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// worldScene is the game itself: it steps the simulation and renders the map.
+type worldScene struct {
+	dialogue *dialogueScene
+}
+
+func (w *worldScene) Enter() {}
+func (w *worldScene) Exit()  {}
+
+func (w *worldScene) Update(dt float64) error {
+	// ESC / P pause the game
+	if inpututil.IsKeyJustPressed(ebiten.KeyEscape) || inpututil.IsKeyJustPressed(ebiten.KeyP) {
+		game.scenes.push(&pauseScene{})
+		// don't step this frame: ESC is also cancel and would close a dialogue
+		return nil
+	}
+
+	game.sim.setInput(pollInput())
+	game.sim.advance(dt)
+	if game.replay != nil && game.replay.done(game.sim.tick) {
+		if err := game.replay.check(); err != nil {
+			fmt.Println("replay finished, outcome differs:", err)
+		} else {
+			fmt.Println("replay finished")
+		}
+		// back to live input
+		game.replay = nil
+	}
+
+	// A conversation started in the simulation gets its own overlay
+	if activeNPC != nil && w.dialogue == nil {
+		w.dialogue = &dialogueScene{}
+		game.scenes.push(w.dialogue)
+	} else if activeNPC == nil && w.dialogue != nil {
+		game.scenes.popScene(w.dialogue)
+		w.dialogue = nil
+	}
+	return nil
+}
+
+func (w *worldScene) Draw(screen *ebiten.Image) {
+	ordered := sortDrawables()
+	drawChunkLayer(screen, mapio.LayerGround)
+	drawChunkLayer(screen, mapio.LayerDetail)
+
+	for _, d := range ordered {
+		d.draw(screen)
+	}
+
+	// roofs, tree tops... cover the entities
+	drawChunkLayer(screen, mapio.LayerOverlay)
+
+	if cfg.debugOverlay {
+		drawDebugOverlay(screen)
+	}
+
+	if len(game.entities.players) > 0 {
+		game.entities.players[0].drawUi()
+	}
+
+	// Draw floating damage after entities so it's on top
+	drawDamageIndicators()
+
+	drawTransition(screen)
+}
+
+// drawDebugOverlay shows the path network and simulation info (-debug-overlay).
+func drawDebugOverlay(screen *ebiten.Image) {
+	for i := 0; i < len(game.currentmap.paths); i++ {
+		drawPath(screen, game.currentmap.paths[i])
+	}
+	for _, p := range game.currentmap.portals {
+		x, y := offsetsx(p.Pos.X), offsetsy(p.Pos.Y)
+		w, h := p.Width*game.camera.zoom, p.Height*game.camera.zoom
+		vector.StrokeRect(screen, x, y, w, h, 2, color.RGBA{80, 140, 255, 255}, false)
+		ebitenutil.DebugPrintAt(screen, "-> "+p.TargetMap+" "+p.TargetSpawn, int(x), int(y)-16)
+	}
+	for _, sp := range game.currentmap.spawnPoints {
+		vector.DrawFilledCircle(screen, offsetsx(sp.Pos.X), offsetsy(sp.Pos.Y), 4, color.RGBA{80, 140, 255, 255}, false)
+		ebitenutil.DebugPrintAt(screen, sp.Name, int(offsetsx(sp.Pos.X))+6, int(offsetsy(sp.Pos.Y))-8)
+	}
+	for i := 0; i < len(game.currentmap.nodes); i++ {
+		n := game.currentmap.nodes[i]
+		ebitenutil.DebugPrintAt(screen, strconv.Itoa(n.id), int(offsetsx(n.pos.float_x)), int(offsetsy(n.pos.float_y)))
+	}
+
+	info := fmt.Sprintf("tick %d  seed %d  entities %d  enemies %d  chunks %d", game.sim.tick, game.rng.seed, len(game.entities.all), len(game.entities.enemies), len(game.currentmap.chunks.loaded))
+	if len(game.entities.players) > 0 {
+		p := game.entities.players[0]
+		tx, ty := ptid(p.pos)
+		info += fmt.Sprintf("\nplayer %.0f,%.0f  tile %d,%d  hp %.1f", p.pos.float_x, p.pos.float_y, tx, ty, p.hp)
+	}
+	ebitenutil.DebugPrintAt(screen, info, 10, 20)
+}
+
+var (
+	resumeBtn    = createButton("Resume", 150, 45, uitransparent, uilightgray, uigray, createPos(50, 60))
+	pauseMenuBtn = createButton("Menu", 150, 45, uitransparent, uilightgray, uigray, createPos(50, 115))
+	pauseExitBtn = createButton("Exit", 150, 45, uitransparent, uilightgray, uigray, createPos(50, 170))
+)
+
+// pauseScene is drawn over the frozen world.
+type pauseScene struct{}
+
+func (p *pauseScene) Enter()             {}
+func (p *pauseScene) Exit()              {}
+func (p *pauseScene) updatesBelow() bool { return false }
+
+// music is squashed while paused
+func (p *pauseScene) musicScale() float64 { return 0.25 }
+
+func (p *pauseScene) Update(dt float64) error {
+	resumeBtn.UpdateButton()
+	pauseMenuBtn.UpdateButton()
+	pauseExitBtn.UpdateButton()
+	defer func() {
+		resumeBtn.pressed = false
+		pauseMenuBtn.pressed = false
+		pauseExitBtn.pressed = false
+	}()
+
+	switch {
+	case resumeBtn.pressed, inpututil.IsKeyJustPressed(ebiten.KeyEscape), inpututil.IsKeyJustPressed(ebiten.KeyP):
+		game.scenes.popScene(p)
+	case pauseMenuBtn.pressed:
+		game.scenes.reset(&menuScene{})
+	case pauseExitBtn.pressed:
+		exitGame()
+	}
+	return nil
+}
+
+func (p *pauseScene) Draw(screen *ebiten.Image) {
+	// Washed overlay (desaturated feel via tinted semi-transparent layer)
+	vector.DrawFilledRect(screen, 0, 0, screenWidth, screenHeight, color.RGBA{40, 40, 40, 170}, false)
+	// Pause panel
+	panelW := float32(260)
+	panelH := float32(200)
+	panelX := (screenWidth - panelW) / 2
+	panelY := (screenHeight - panelH) / 2
+	vector.DrawFilledRect(screen, panelX+4, panelY+4, panelW, panelH, color.RGBA{0, 0, 0, 120}, false) // shadow
+	vector.DrawFilledRect(screen, panelX, panelY, panelW, panelH, color.RGBA{70, 80, 75, 230}, false)
+	// Title
+	fmtStr := "PAUSED"
+	fbx := int(panelX + (panelW-float32(len(fmtStr))*7)/2)
+	fby := int(panelY + 10)
+	ebitenutil.DebugPrintAt(screen, fmtStr, fbx, fby)
+	// Reposition pause buttons relative to panel for neat layout
+	resumeBtn.pos.float_x = panelX + panelW/2 - resumeBtn.width/2
+	resumeBtn.pos.float_y = panelY + 50
+	pauseMenuBtn.pos.float_x = panelX + panelW/2 - pauseMenuBtn.width/2
+	pauseMenuBtn.pos.float_y = panelY + 100
+	pauseExitBtn.pos.float_x = panelX + panelW/2 - pauseExitBtn.width/2
+	pauseExitBtn.pos.float_y = panelY + 150
+	resumeBtn.DrawButton(screen)
+	pauseMenuBtn.DrawButton(screen)
+	pauseExitBtn.DrawButton(screen)
+}